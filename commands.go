@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vandenbill/social-media-10k-rps/db"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/pkg/migrate"
+	"github.com/vandenbill/social-media-10k-rps/pkg/postgre"
+)
+
+const usage = `usage:
+  %[1]s                  run the server
+  %[1]s config print     print the config with secrets redacted
+  %[1]s migrate up       apply every pending migration
+  %[1]s migrate down [N] revert the last N migrations, 1 by default
+  %[1]s migrate status   list the migrations and which are applied
+  %[1]s migrate version  print the current schema version
+`
+
+// runCommand runs the subcommand in args instead of the server and returns
+// the exit code.
+func runCommand(args []string, cfg *cfg.Cfg, cfgErr error) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		// print even an invalid config, it helps finding out why
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "fail print config:", err)
+			return 1
+		}
+		if cfgErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", cfgErr)
+			return 1
+		}
+		return 0
+	case len(args) >= 2 && args[0] == "migrate":
+		if cfgErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", cfgErr)
+			return 1
+		}
+		if err := runMigrate(args[1:], cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", strings.Join(args, " "), fmt.Sprintf(usage, os.Args[0]))
+	return 2
+}
+
+func runMigrate(args []string, cfg *cfg.Cfg) error {
+	ctx := context.Background()
+
+	conn := postgre.GetConn(ctx, postgreConfig(cfg), nil)
+	defer conn.Close()
+
+	migrator, err := migrate.New(conn, db.Migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case len(args) <= 2 && args[0] == "down":
+		n := 1
+		if len(args) == 2 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("N must be a positive number, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, n)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case len(args) == 1 && args[0] == "status":
+		statuses, version, dirty, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			if dirty && s.Version == version {
+				state = "dirty"
+			}
+			fmt.Printf("%06d  %-8s %s\n", s.Version, state, s.Name)
+		}
+		return nil
+	case len(args) == 1 && args[0] == "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n\n%s", strings.Join(args, " "), fmt.Sprintf(usage, os.Args[0]))
+}
@@ -0,0 +1,10 @@
+// Package db ships the schema migrations inside the binary.
+package db
+
+import "embed"
+
+// Migrations holds migrations/NNNNNN_name.{up,down}.sql in golang-migrate's
+// layout.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
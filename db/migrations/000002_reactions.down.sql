@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS COMMENT_REACTION_COUNTS;
+
+DROP TABLE IF EXISTS POST_REACTION_COUNTS;
+
+DROP TABLE IF EXISTS COMMENT_REACTIONS;
+
+DROP TABLE IF EXISTS POST_REACTIONS;
\ No newline at end of file
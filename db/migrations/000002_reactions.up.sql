@@ -0,0 +1,34 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE POST_REACTIONS (
+    post_id UUID REFERENCES POSTS(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES USERS(id) ON DELETE CASCADE,
+    reaction VARCHAR NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE COMMENT_REACTIONS (
+    comment_id INT REFERENCES COMMENTS(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES USERS(id) ON DELETE CASCADE,
+    reaction VARCHAR NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+-- counters are maintained together with the reaction rows so reads never COUNT(*)
+CREATE TABLE POST_REACTION_COUNTS (
+    post_id UUID REFERENCES POSTS(id) ON DELETE CASCADE,
+    reaction VARCHAR NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, reaction)
+);
+
+CREATE TABLE COMMENT_REACTION_COUNTS (
+    comment_id INT REFERENCES COMMENTS(id) ON DELETE CASCADE,
+    reaction VARCHAR NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (comment_id, reaction)
+);
+
+COMMIT TRANSACTION;
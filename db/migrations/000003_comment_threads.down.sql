@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS comments_parent_comment_id_idx;
+
+ALTER TABLE COMMENTS
+    DROP COLUMN IF EXISTS reply_count,
+    DROP COLUMN IF EXISTS depth,
+    DROP COLUMN IF EXISTS parent_comment_id;
+
+COMMIT TRANSACTION;
\ No newline at end of file
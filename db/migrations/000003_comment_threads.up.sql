@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE COMMENTS
+    ADD COLUMN parent_comment_id INT REFERENCES COMMENTS(id) ON DELETE CASCADE,
+    ADD COLUMN depth INT NOT NULL DEFAULT 0,
+    ADD COLUMN reply_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX comments_parent_comment_id_idx ON COMMENTS (parent_comment_id, created_at);
+
+COMMIT TRANSACTION;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS NOTIFICATIONS;
+
+DROP TABLE IF EXISTS MENTIONS;
\ No newline at end of file
@@ -0,0 +1,28 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE MENTIONS (
+    id SERIAL PRIMARY KEY,
+    post_id UUID REFERENCES POSTS(id) ON DELETE CASCADE,
+    comment_id INT REFERENCES COMMENTS(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES USERS(id) ON DELETE CASCADE,
+    start_at INT NOT NULL,
+    end_at INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX mentions_post_id_idx ON MENTIONS (post_id);
+CREATE INDEX mentions_comment_id_idx ON MENTIONS (comment_id);
+
+CREATE TABLE NOTIFICATIONS (
+    id SERIAL PRIMARY KEY,
+    user_id UUID REFERENCES USERS(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES USERS(id) ON DELETE CASCADE,
+    type VARCHAR NOT NULL,
+    post_id UUID REFERENCES POSTS(id) ON DELETE CASCADE,
+    comment_id INT REFERENCES COMMENTS(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX notifications_user_id_idx ON NOTIFICATIONS (user_id, created_at DESC);
+
+COMMIT TRANSACTION;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS tags_created_at_idx;
+DROP INDEX IF EXISTS tags_tag_created_at_idx;
+
+ALTER TABLE TAGS DROP CONSTRAINT IF EXISTS unique_post_tag;
+
+COMMIT TRANSACTION;
\ No newline at end of file
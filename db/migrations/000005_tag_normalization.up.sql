@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+UPDATE TAGS SET tag = LOWER(TRIM(tag));
+
+DELETE FROM TAGS WHERE tag IS NULL OR tag = '';
+
+DELETE FROM TAGS t USING TAGS d
+WHERE t.post_id = d.post_id AND t.tag = d.tag AND t.id > d.id;
+
+ALTER TABLE TAGS ADD CONSTRAINT unique_post_tag UNIQUE (post_id, tag);
+
+CREATE INDEX tags_tag_created_at_idx ON TAGS (tag, created_at);
+CREATE INDEX tags_created_at_idx ON TAGS (created_at);
+
+COMMIT TRANSACTION;
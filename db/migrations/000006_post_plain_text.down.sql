@@ -0,0 +1 @@
+ALTER TABLE POSTS DROP COLUMN IF EXISTS content_text;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE POSTS ADD COLUMN content_text VARCHAR;
+
+UPDATE POSTS SET content_text = TRIM(regexp_replace(regexp_replace(content, '<[^>]*>', ' ', 'g'), '\s+', ' ', 'g'));
+
+COMMIT TRANSACTION;
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS posts_search_idx;
+
+ALTER TABLE POSTS
+    DROP COLUMN IF EXISTS search,
+    DROP COLUMN IF EXISTS tags_text;
+
+COMMIT TRANSACTION;
\ No newline at end of file
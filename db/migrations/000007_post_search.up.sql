@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE POSTS ADD COLUMN tags_text VARCHAR;
+
+UPDATE POSTS p SET tags_text = (SELECT string_agg(t.tag, ' ') FROM TAGS t WHERE t.post_id = p.id);
+
+ALTER TABLE POSTS ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE(tags_text, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(content_text, '')), 'B')
+) STORED;
+
+CREATE INDEX posts_search_idx ON POSTS USING GIN (search);
+
+COMMIT TRANSACTION;
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE USERS
+    DROP COLUMN IF EXISTS image_media_id,
+    DROP COLUMN IF EXISTS storage_used;
+
+DROP TABLE IF EXISTS MEDIA;
+
+COMMIT TRANSACTION;
\ No newline at end of file
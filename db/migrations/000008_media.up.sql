@@ -0,0 +1,20 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE MEDIA (
+    id UUID PRIMARY KEY,
+    owner UUID REFERENCES USERS(id) ON DELETE SET NULL,
+    key VARCHAR NOT NULL,
+    size BIGINT NOT NULL,
+    hash VARCHAR NOT NULL,
+    mime VARCHAR NOT NULL,
+    variants JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX media_owner_idx ON MEDIA (owner);
+
+ALTER TABLE USERS
+    ADD COLUMN storage_used BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN image_media_id UUID REFERENCES MEDIA(id) ON DELETE SET NULL;
+
+COMMIT TRANSACTION;
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS media_owner_key_idx;
+DROP INDEX IF EXISTS media_owner_hash_idx;
+
+ALTER TABLE MEDIA DROP CONSTRAINT IF EXISTS media_key_fkey;
+
+DROP TABLE IF EXISTS BLOBS;
+
+COMMIT TRANSACTION;
\ No newline at end of file
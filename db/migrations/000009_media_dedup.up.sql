@@ -0,0 +1,24 @@
+BEGIN TRANSACTION;
+
+-- stored objects, shared by every media row with the same key
+CREATE TABLE BLOBS (
+    key VARCHAR PRIMARY KEY,
+    hash VARCHAR NOT NULL,
+    size BIGINT NOT NULL,
+    mime VARCHAR NOT NULL,
+    variants JSONB NOT NULL,
+    ref_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX blobs_hash_idx ON BLOBS (hash);
+
+INSERT INTO BLOBS (key, hash, size, mime, variants, ref_count, created_at)
+SELECT key, hash, size, mime, variants, 1, created_at FROM MEDIA;
+
+ALTER TABLE MEDIA ADD CONSTRAINT media_key_fkey FOREIGN KEY (key) REFERENCES BLOBS(key);
+
+CREATE INDEX media_owner_hash_idx ON MEDIA (owner, hash);
+CREATE INDEX media_owner_key_idx ON MEDIA (owner, key);
+
+COMMIT TRANSACTION;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS POST_ATTACHMENTS;
\ No newline at end of file
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE POST_ATTACHMENTS (
+    post_id UUID REFERENCES POSTS(id) ON DELETE CASCADE,
+    media_id UUID NOT NULL REFERENCES MEDIA(id),
+    position INT NOT NULL,
+    alt_text VARCHAR NOT NULL DEFAULT '',
+    PRIMARY KEY (post_id, position)
+);
+
+CREATE INDEX post_attachments_media_id_idx ON POST_ATTACHMENTS (media_id);
+
+COMMIT TRANSACTION;
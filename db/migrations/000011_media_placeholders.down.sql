@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE MEDIA
+    DROP COLUMN blur_hash,
+    DROP COLUMN color;
+
+ALTER TABLE BLOBS
+    DROP COLUMN blur_hash,
+    DROP COLUMN color;
+
+COMMIT TRANSACTION;
\ No newline at end of file
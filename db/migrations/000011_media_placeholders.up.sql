@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE BLOBS
+    ADD COLUMN blur_hash VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN color VARCHAR NOT NULL DEFAULT '';
+
+ALTER TABLE MEDIA
+    ADD COLUMN blur_hash VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN color VARCHAR NOT NULL DEFAULT '';
+
+COMMIT TRANSACTION;
@@ -0,0 +1,57 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE POST_ATTACHMENTS
+    DROP CONSTRAINT IF EXISTS post_attachments_position_positive,
+    DROP CONSTRAINT IF EXISTS post_attachments_alt_text_length;
+
+ALTER TABLE COMMENT_REACTION_COUNTS DROP CONSTRAINT IF EXISTS comment_reaction_counts_count_positive;
+ALTER TABLE POST_REACTION_COUNTS DROP CONSTRAINT IF EXISTS post_reaction_counts_count_positive;
+ALTER TABLE COMMENT_REACTIONS DROP CONSTRAINT IF EXISTS comment_reactions_reaction;
+ALTER TABLE POST_REACTIONS DROP CONSTRAINT IF EXISTS post_reactions_reaction;
+
+ALTER TABLE COMMENTS
+    DROP CONSTRAINT IF EXISTS comments_reply_count_positive,
+    DROP CONSTRAINT IF EXISTS comments_depth_positive,
+    DROP CONSTRAINT IF EXISTS comments_comment_length,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN comment DROP NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL,
+    ALTER COLUMN post_id DROP NOT NULL;
+
+ALTER TABLE TAGS
+    DROP CONSTRAINT IF EXISTS tags_tag_not_empty,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN post_id DROP NOT NULL,
+    ALTER COLUMN tag DROP NOT NULL;
+
+ALTER TABLE POSTS
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN creator DROP NOT NULL,
+    ALTER COLUMN tags_text DROP NOT NULL,
+    ALTER COLUMN content_text DROP NOT NULL,
+    ALTER COLUMN content DROP NOT NULL;
+
+ALTER TABLE FRIENDS
+    DROP CONSTRAINT IF EXISTS friends_not_self,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN b DROP NOT NULL,
+    ALTER COLUMN a DROP NOT NULL;
+
+ALTER TABLE USERS
+    DROP CONSTRAINT IF EXISTS users_storage_used_positive,
+    DROP CONSTRAINT IF EXISTS users_phone_number_length,
+    DROP CONSTRAINT IF EXISTS users_credential,
+    DROP CONSTRAINT IF EXISTS users_name_length,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN password DROP NOT NULL,
+    ALTER COLUMN name DROP NOT NULL;
+
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS posts_creator_created_at_idx;
+DROP INDEX IF EXISTS comments_user_id_idx;
+DROP INDEX IF EXISTS comments_post_id_idx;
+DROP INDEX IF EXISTS friends_b_idx;
+
+-- pg_trgm stays, it may have been installed before and other objects may use it
+
+COMMIT TRANSACTION;
\ No newline at end of file
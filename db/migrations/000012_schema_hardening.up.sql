@@ -0,0 +1,136 @@
+-- Heads up before running this on a live database:
+--  * the indexes are built with plain CREATE INDEX and the NOT NULL and CHECK
+--    constraints scan their tables, all in one transaction. Writes to users,
+--    friends, posts, tags and comments block until it commits. Build the
+--    indexes first with CREATE INDEX CONCURRENTLY under the same names, they
+--    are skipped here then, and run it in a quiet period.
+--  * nothing is deleted. When rows break one of the new constraints the
+--    migration fails before changing anything and lists them, up to 10 per
+--    constraint. Fix or remove them and migrate again.
+BEGIN TRANSACTION;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- unique_friendship (a, b) already serves lookups by a, b needs its own for
+-- the reverse side and the cascade when a user is deleted
+CREATE INDEX IF NOT EXISTS friends_b_idx ON FRIENDS (b);
+-- unique_post_tag (post_id, tag) already serves lookups by post_id
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON COMMENTS (post_id, created_at);
+CREATE INDEX IF NOT EXISTS comments_user_id_idx ON COMMENTS (user_id);
+CREATE INDEX IF NOT EXISTS posts_creator_created_at_idx ON POSTS (creator, created_at DESC);
+-- LOWER(name) LIKE '%...%' in the friends search
+CREATE INDEX IF NOT EXISTS users_name_trgm_idx ON USERS USING GIN (LOWER(name) gin_trgm_ops);
+
+-- columns the database fills itself, a NULL there loses nothing
+UPDATE POSTS SET content_text = '' WHERE content_text IS NULL;
+UPDATE POSTS SET tags_text = '' WHERE tags_text IS NULL;
+
+UPDATE USERS SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE FRIENDS SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE POSTS SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE TAGS SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE COMMENTS SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+
+-- every other constraint below, each with the rows that would break it
+DO $$
+DECLARE
+    checks TEXT[] := ARRAY[
+        'users.name NOT NULL', $q$SELECT id::text FROM USERS WHERE name IS NULL$q$,
+        'users.password NOT NULL', $q$SELECT id::text FROM USERS WHERE password IS NULL$q$,
+        'users_name_length', $q$SELECT id::text FROM USERS WHERE NOT char_length(name) BETWEEN 5 AND 50$q$,
+        'users_credential', $q$SELECT id::text FROM USERS WHERE email IS NULL AND phone_number IS NULL$q$,
+        'users_phone_number_length', $q$SELECT id::text FROM USERS WHERE char_length(phone_number) > 16$q$,
+        'users_storage_used_positive', $q$SELECT id::text FROM USERS WHERE storage_used < 0$q$,
+        'friends.a NOT NULL', $q$SELECT id::text FROM FRIENDS WHERE a IS NULL$q$,
+        'friends.b NOT NULL', $q$SELECT id::text FROM FRIENDS WHERE b IS NULL$q$,
+        'friends_not_self', $q$SELECT id::text FROM FRIENDS WHERE a = b$q$,
+        'posts.content NOT NULL', $q$SELECT id::text FROM POSTS WHERE content IS NULL$q$,
+        'posts.creator NOT NULL', $q$SELECT id::text FROM POSTS WHERE creator IS NULL$q$,
+        'tags.tag NOT NULL', $q$SELECT id::text FROM TAGS WHERE tag IS NULL$q$,
+        'tags.post_id NOT NULL', $q$SELECT id::text FROM TAGS WHERE post_id IS NULL$q$,
+        'tags_tag_not_empty', $q$SELECT id::text FROM TAGS WHERE tag = ''$q$,
+        'comments.post_id NOT NULL', $q$SELECT id::text FROM COMMENTS WHERE post_id IS NULL$q$,
+        'comments.user_id NOT NULL', $q$SELECT id::text FROM COMMENTS WHERE user_id IS NULL$q$,
+        'comments.comment NOT NULL', $q$SELECT id::text FROM COMMENTS WHERE comment IS NULL$q$,
+        'comments_comment_length', $q$SELECT id::text FROM COMMENTS WHERE NOT char_length(comment) BETWEEN 2 AND 500$q$,
+        'comments_depth_positive', $q$SELECT id::text FROM COMMENTS WHERE depth < 0$q$,
+        'comments_reply_count_positive', $q$SELECT id::text FROM COMMENTS WHERE reply_count < 0$q$,
+        'post_reactions_reaction', $q$SELECT post_id || '/' || user_id FROM POST_REACTIONS WHERE reaction NOT IN ('like', 'love', 'haha', 'wow', 'sad', 'angry')$q$,
+        'comment_reactions_reaction', $q$SELECT comment_id || '/' || user_id FROM COMMENT_REACTIONS WHERE reaction NOT IN ('like', 'love', 'haha', 'wow', 'sad', 'angry')$q$,
+        'post_reaction_counts_count_positive', $q$SELECT post_id || '/' || reaction FROM POST_REACTION_COUNTS WHERE count < 0$q$,
+        'comment_reaction_counts_count_positive', $q$SELECT comment_id || '/' || reaction FROM COMMENT_REACTION_COUNTS WHERE count < 0$q$,
+        'post_attachments_alt_text_length', $q$SELECT post_id || '/' || position FROM POST_ATTACHMENTS WHERE char_length(alt_text) > 200$q$,
+        'post_attachments_position_positive', $q$SELECT post_id || '/' || position FROM POST_ATTACHMENTS WHERE position < 0$q$
+    ];
+    offending TEXT;
+    problems TEXT := '';
+BEGIN
+    FOR i IN 1 .. array_length(checks, 1) BY 2 LOOP
+        EXECUTE format('SELECT string_agg(k, '', '') FROM (%s LIMIT 10) v(k)', checks[i + 1]) INTO offending;
+        IF offending IS NOT NULL THEN
+            problems := problems || format(E'\n  %s: %s', checks[i], offending);
+        END IF;
+    END LOOP;
+
+    IF problems <> '' THEN
+        RAISE EXCEPTION 'rows break the constraints 000012 adds, fix or remove them and migrate again:%', problems;
+    END IF;
+END
+$$;
+
+-- lengths follow the validate tags in internal/dto. Post content isn't
+-- limited, sanitizing escapes it and can push it past what was validated.
+ALTER TABLE USERS
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN password SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD CONSTRAINT users_name_length CHECK (char_length(name) BETWEEN 5 AND 50),
+    ADD CONSTRAINT users_credential CHECK (email IS NOT NULL OR phone_number IS NOT NULL),
+    ADD CONSTRAINT users_phone_number_length CHECK (char_length(phone_number) <= 16),
+    ADD CONSTRAINT users_storage_used_positive CHECK (storage_used >= 0);
+
+ALTER TABLE FRIENDS
+    ALTER COLUMN a SET NOT NULL,
+    ALTER COLUMN b SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD CONSTRAINT friends_not_self CHECK (a <> b);
+
+ALTER TABLE POSTS
+    ALTER COLUMN content SET NOT NULL,
+    ALTER COLUMN content_text SET NOT NULL,
+    ALTER COLUMN tags_text SET NOT NULL,
+    ALTER COLUMN creator SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL;
+
+ALTER TABLE TAGS
+    ALTER COLUMN tag SET NOT NULL,
+    ALTER COLUMN post_id SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD CONSTRAINT tags_tag_not_empty CHECK (tag <> '');
+
+ALTER TABLE COMMENTS
+    ALTER COLUMN post_id SET NOT NULL,
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN comment SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD CONSTRAINT comments_comment_length CHECK (char_length(comment) BETWEEN 2 AND 500),
+    ADD CONSTRAINT comments_depth_positive CHECK (depth >= 0),
+    ADD CONSTRAINT comments_reply_count_positive CHECK (reply_count >= 0);
+
+ALTER TABLE POST_REACTIONS
+    ADD CONSTRAINT post_reactions_reaction CHECK (reaction IN ('like', 'love', 'haha', 'wow', 'sad', 'angry'));
+
+ALTER TABLE COMMENT_REACTIONS
+    ADD CONSTRAINT comment_reactions_reaction CHECK (reaction IN ('like', 'love', 'haha', 'wow', 'sad', 'angry'));
+
+ALTER TABLE POST_REACTION_COUNTS
+    ADD CONSTRAINT post_reaction_counts_count_positive CHECK (count >= 0);
+
+ALTER TABLE COMMENT_REACTION_COUNTS
+    ADD CONSTRAINT comment_reaction_counts_count_positive CHECK (count >= 0);
+
+ALTER TABLE POST_ATTACHMENTS
+    ADD CONSTRAINT post_attachments_alt_text_length CHECK (char_length(alt_text) <= 200),
+    ADD CONSTRAINT post_attachments_position_positive CHECK (position >= 0);
+
+COMMIT TRANSACTION;
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS posts_content_unsanitized_idx;
+
+ALTER TABLE POSTS DROP COLUMN content_sanitized;
+
+COMMIT TRANSACTION;
\ No newline at end of file
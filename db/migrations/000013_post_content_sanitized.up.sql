@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+-- rows written before content was sanitized on insert are false, the server
+-- sanitizes them on read and rewrites them in the background. New rows come
+-- from the sanitizing insert and default to true.
+ALTER TABLE POSTS ADD COLUMN content_sanitized BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE POSTS ALTER COLUMN content_sanitized SET DEFAULT true;
+
+CREATE INDEX posts_content_unsanitized_idx ON POSTS (id) WHERE NOT content_sanitized;
+
+COMMIT TRANSACTION;
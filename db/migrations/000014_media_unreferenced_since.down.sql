@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+
+DROP TRIGGER IF EXISTS users_media_reference ON USERS;
+DROP TRIGGER IF EXISTS post_attachments_media_reference ON POST_ATTACHMENTS;
+DROP FUNCTION IF EXISTS media_reference_changed();
+DROP FUNCTION IF EXISTS media_refresh_unreferenced(UUID);
+
+DROP INDEX IF EXISTS media_unreferenced_since_idx;
+
+ALTER TABLE MEDIA DROP COLUMN unreferenced_since;
+
+COMMIT TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,56 @@
+BEGIN TRANSACTION;
+
+-- when the media last lost its last reference, or was uploaded or reused
+-- without one. NULL while a profile or a post uses it. The collector goes by
+-- this instead of created_at so media is kept for the full age after it
+-- stops being used.
+ALTER TABLE MEDIA ADD COLUMN unreferenced_since TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- existing unused media gets the full age from now on
+UPDATE MEDIA m SET unreferenced_since = NULL
+WHERE EXISTS (SELECT 1 FROM USERS u WHERE u.image_media_id = m.id)
+    OR EXISTS (SELECT 1 FROM POST_ATTACHMENTS pa WHERE pa.media_id = m.id);
+
+CREATE INDEX media_unreferenced_since_idx ON MEDIA (unreferenced_since) WHERE unreferenced_since IS NOT NULL;
+
+-- kept up to date by triggers rather than by the repo, references also go
+-- away through cascades (a deleted post drops its attachments) the code
+-- never sees
+CREATE FUNCTION media_refresh_unreferenced(media_id UUID) RETURNS VOID AS $$
+    UPDATE MEDIA m SET unreferenced_since = CASE
+        WHEN EXISTS (SELECT 1 FROM USERS u WHERE u.image_media_id = m.id)
+            OR EXISTS (SELECT 1 FROM POST_ATTACHMENTS pa WHERE pa.media_id = m.id)
+        THEN NULL
+        ELSE COALESCE(m.unreferenced_since, CURRENT_TIMESTAMP)
+    END
+    WHERE m.id = media_id;
+$$ LANGUAGE SQL;
+
+CREATE FUNCTION media_reference_changed() RETURNS TRIGGER AS $$
+DECLARE
+    old_id UUID;
+    new_id UUID;
+BEGIN
+    IF TG_TABLE_NAME = 'users' THEN
+        IF TG_OP <> 'INSERT' THEN old_id := OLD.image_media_id; END IF;
+        IF TG_OP <> 'DELETE' THEN new_id := NEW.image_media_id; END IF;
+    ELSE
+        IF TG_OP <> 'INSERT' THEN old_id := OLD.media_id; END IF;
+        IF TG_OP <> 'DELETE' THEN new_id := NEW.media_id; END IF;
+    END IF;
+
+    IF old_id IS NOT NULL THEN PERFORM media_refresh_unreferenced(old_id); END IF;
+    IF new_id IS NOT NULL AND new_id IS DISTINCT FROM old_id THEN PERFORM media_refresh_unreferenced(new_id); END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER post_attachments_media_reference
+AFTER INSERT OR UPDATE OF media_id OR DELETE ON POST_ATTACHMENTS
+FOR EACH ROW EXECUTE FUNCTION media_reference_changed();
+
+CREATE TRIGGER users_media_reference
+AFTER INSERT OR UPDATE OF image_media_id OR DELETE ON USERS
+FOR EACH ROW EXECUTE FUNCTION media_reference_changed();
+
+COMMIT TRANSACTION;
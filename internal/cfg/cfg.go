@@ -1,49 +1,312 @@
+// Package cfg loads the configuration. Every field is read from the
+// environment variable in its env tag, then from the YAML file named by
+// CONFIG_FILE under the same name in lower case, then falls back to its
+// default tag. Fields tagged secret are redacted by Print.
 package cfg
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
 )
 
 type Cfg struct {
-	DBName         string
-	DBPort         int
-	DBHost         string
-	DBUsername     string
-	DBPassword     string
-	PrometheusAddr string
-	JWTSecret      string
-	BCryptSalt     int
-	S3ID           string
-	S3SecretKey    string
-	S3BucketName   string
-	S3Region   string
+	DBName     string `env:"DB_NAME" validate:"required"`
+	DBPort     int    `env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	DBHost     string `env:"DB_HOST" validate:"required"`
+	DBUsername string `env:"DB_USERNAME" validate:"required"`
+	DBPassword string `env:"DB_PASSWORD" secret:"true"`
+	// Env is "production" on the production deploy, it defaults DBSSLMode to
+	// verify-full against the RDS bundle
+	Env string `env:"ENV"`
+	// DBSSLMode is passed on as sslmode, prefer unless Env says otherwise
+	DBSSLMode         string        `env:"DB_SSL_MODE" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	DBSSLRootCert     string        `env:"DB_SSL_ROOT_CERT"`
+	DBMaxConns        int           `env:"DB_MAX_CONNS" default:"100" validate:"min=1"`
+	DBMaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME" default:"30m" validate:"gt=0"`
+	DBMaxConnLifetime time.Duration `env:"DB_MAX_CONN_LIFETIME" default:"1h" validate:"gt=0"`
+	DBConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT" default:"5s" validate:"gt=0"`
+	PrometheusAddr    string        `env:"PROMETHEUS_ADDRESS" default:":9091" validate:"required"`
+	JWTSecret         string        `env:"JWT_SECRET" validate:"required" secret:"true"`
+	JWTExpiry         time.Duration `env:"JWT_EXPIRY" default:"8h" validate:"gt=0"`
+	BCryptSalt        int           `env:"BCRYPT_SALT" default:"10" validate:"min=4,max=31"`
+	S3ID              string        `env:"S3_ID" validate:"required_if=StorageBackend s3" secret:"true"`
+	S3SecretKey       string        `env:"S3_SECRET_KEY" validate:"required_if=StorageBackend s3" secret:"true"`
+	S3BucketName      string        `env:"S3_BUCKET_NAME" validate:"required_if=StorageBackend s3"`
+	S3Region          string        `env:"S3_REGION" default:"ap-southeast-1"`
+	S3Endpoint        string        `env:"S3_ENDPOINT"`
+	S3BaseURL         string        `env:"S3_BASE_URL"`
+
+	// StorageBackend is either "s3" or "local"
+	StorageBackend      string `env:"STORAGE_BACKEND" default:"s3" validate:"oneof=s3 local"`
+	LocalStorageDir     string `env:"LOCAL_STORAGE_DIR" default:"./uploads" validate:"required_if=StorageBackend local"`
+	LocalStorageBaseURL string `env:"LOCAL_STORAGE_BASE_URL" default:"http://localhost:8080/v1/image" validate:"required_if=StorageBackend local"`
+
+	UploadMinBytes     int64         `env:"UPLOAD_MIN_BYTES" default:"10240" validate:"min=0,ltefield=UploadMaxBytes"`
+	UploadMaxBytes     int64         `env:"UPLOAD_MAX_BYTES" default:"2097152" validate:"min=1"`
+	UploadAllowedTypes []string      `env:"UPLOAD_ALLOWED_TYPES" default:"image/jpeg,image/png,image/webp,image/gif" validate:"min=1"`
+	UploadMaxWidth     int           `env:"UPLOAD_MAX_WIDTH" default:"4096" validate:"min=1"`
+	UploadMaxHeight    int           `env:"UPLOAD_MAX_HEIGHT" default:"4096" validate:"min=1"`
+	PresignExpiry      time.Duration `env:"PRESIGN_EXPIRY" default:"15m" validate:"gt=0"`
+	UserStorageQuota   int64         `env:"USER_STORAGE_QUOTA" default:"104857600" validate:"min=0"`
+	// UploadDedupScope is "owner" to reuse a user's own identical uploads or
+	// "global" to also share stored objects between users
+	UploadDedupScope string `env:"UPLOAD_DEDUP_SCOPE" default:"owner" validate:"oneof=owner global"`
+
+	MediaGCInterval time.Duration `env:"MEDIA_GC_INTERVAL" default:"1h" validate:"gt=0"`
+	// MediaGCMinAge is how long media must have gone unreferenced, or unused
+	// since upload, before it is collected
+	MediaGCMinAge    time.Duration `env:"MEDIA_GC_MIN_AGE" default:"24h" validate:"gt=0"`
+	MediaGCBatchSize int           `env:"MEDIA_GC_BATCH_SIZE" default:"100" validate:"min=1"`
+	MediaGCDryRun    bool          `env:"MEDIA_GC_DRY_RUN" default:"false"`
+
+	TrendingTagsWindow   time.Duration `env:"TRENDING_TAGS_WINDOW" default:"24h" validate:"gt=0"`
+	TrendingTagsInterval time.Duration `env:"TRENDING_TAGS_INTERVAL" default:"1m" validate:"gt=0"`
+	TrendingTagsLimit    int           `env:"TRENDING_TAGS_LIMIT" default:"10" validate:"min=1"`
+
+	HTTPAddr              string        `env:"HTTP_ADDRESS" default:":8080" validate:"required"`
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" default:"15s" validate:"gt=0"`
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"5s" validate:"gt=0"`
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"30s" validate:"gt=0"`
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"60s" validate:"gt=0"`
+	// ShutdownTimeout bounds draining requests and stopping workers on SIGTERM
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" validate:"gt=0"`
+
+	// AutoMigrate applies pending migrations on start, replicas take turns
+	// through an advisory lock
+	AutoMigrate      bool          `env:"AUTO_MIGRATE" default:"false"`
+	ReadinessTimeout time.Duration `env:"READINESS_TIMEOUT" default:"2s" validate:"gt=0"`
+	// ShutdownDrainDelay is how long readiness fails before the server stops
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" default:"5s" validate:"min=0"`
+
+	// LogLevel is debug, info, warn or error. Successful requests are logged
+	// LogSampleInitial times a second, then every LogSampleThereafter-th.
+	LogLevel            string `env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error DEBUG INFO WARN ERROR"`
+	LogSampleInitial    int    `env:"LOG_SAMPLE_INITIAL" default:"100" validate:"min=0"`
+	LogSampleThereafter int    `env:"LOG_SAMPLE_THEREAFTER" default:"100" validate:"min=0"`
+
+	// TracingExporter is "none", "stdout" for local runs or "otlp"
+	TracingExporter    string  `env:"TRACING_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint       string  `env:"OTLP_ENDPOINT" default:"http://localhost:4318" validate:"required_if=TracingExporter otlp,omitempty,url"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1" validate:"min=0,max=1"`
 }
 
-func Load() *Cfg {
-	var err error
+// Load reads the configuration and checks it. The returned error lists every
+// field that is missing or invalid, the config is returned along with it so
+// it can still be printed.
+func Load() (*Cfg, error) {
 	cfg := &Cfg{}
 
-	cfg.DBName = os.Getenv("DB_NAME")
-	cfg.DBHost = os.Getenv("DB_HOST")
-	cfg.DBUsername = os.Getenv("DB_USERNAME")
-	cfg.DBPassword = os.Getenv("DB_PASSWORD")
-	cfg.PrometheusAddr = os.Getenv("PROMETHEUS_ADDRESS")
-	cfg.JWTSecret = os.Getenv("JWT_SECRET")
-	cfg.S3ID = os.Getenv("S3_ID")
-	cfg.S3SecretKey = os.Getenv("S3_SECRET_KEY")
-	cfg.S3BucketName = os.Getenv("S3_BUCKET_NAME")
-	cfg.S3Region = os.Getenv("S3_REGION")
-
-	cfg.BCryptSalt, err = strconv.Atoi(os.Getenv("BCRYPT_SALT"))
+	file, err := readFile(os.Getenv("CONFIG_FILE"))
 	if err != nil {
-		log.Fatal("fail convert bcrypt salt to int:", err)
+		return cfg, err
+	}
+
+	var errs []error
+	invalid := map[string]bool{}
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		key := f.Tag.Get("env")
+
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			raw, ok = file[strings.ToLower(key)]
+		}
+		if !ok {
+			raw = f.Tag.Get("default")
+		}
+		delete(file, strings.ToLower(key))
+
+		if err := set(v.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			invalid[key] = true
+		}
+	}
+	unknown := make([]string, 0, len(file))
+	for k := range file {
+		unknown = append(unknown, k)
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown key in %s", k, os.Getenv("CONFIG_FILE")))
+	}
+
+	applyEnv(cfg)
+
+	if err := validate(cfg, invalid); err != nil {
+		errs = append(errs, err)
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// applyEnv fills in defaults that depend on Env, values set explicitly win.
+func applyEnv(cfg *Cfg) {
+	if cfg.DBSSLMode != "" {
+		return
+	}
+
+	cfg.DBSSLMode = "prefer"
+	if cfg.Env == "production" {
+		cfg.DBSSLMode = "verify-full"
+		if cfg.DBSSLRootCert == "" {
+			cfg.DBSSLRootCert = "ap-southeast-1-bundle.pem"
+		}
+	}
+}
+
+// readFile flattens the YAML file into raw values, lists are joined with
+// commas the same way they are written in the environment.
+func readFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+	if path == "" {
+		return values, nil
 	}
-	cfg.DBPort, err = strconv.Atoi(os.Getenv("DB_PORT"))
+
+	b, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal("fail convert db port to int:", err)
+		return nil, fmt.Errorf("fail read config file: %w", err)
+	}
+
+	var doc map[string]any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("fail parse config file %s: %w", path, err)
+	}
+
+	for k, v := range doc {
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			items := make([]string, len(v))
+			for i := range v {
+				items[i] = fmt.Sprint(v[i])
+			}
+			values[k] = strings.Join(items, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("fail parse config file %s: %s must not be a mapping", path, k)
+		default:
+			values[k] = fmt.Sprint(v)
+		}
 	}
+	return values, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
 
-	return cfg
+func set(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		if raw == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		if raw == "" {
+			return nil
+		}
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(i)
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// validate checks cfg against the validate tags, fields in skip already
+// failed to parse and aren't reported twice.
+func validate(cfg *Cfg, skip map[string]bool) error {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return f.Tag.Get("env")
+	})
+
+	err := v.Struct(cfg)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	errs := make([]error, 0, len(verrs))
+	for _, e := range verrs {
+		if skip[e.Field()] {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", e.Field(), describe(e)))
+	}
+	return errors.Join(errs...)
+}
+
+func describe(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		param := strings.Fields(e.Param())
+		field, _ := reflect.TypeOf(Cfg{}).FieldByName(param[0])
+		return fmt.Sprintf("is required when %s is %s", field.Tag.Get("env"), param[1])
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(strings.Fields(e.Param()), ", "), e.Value())
+	case "min":
+		if e.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at least %s item(s)", e.Param())
+		}
+		return fmt.Sprintf("must be at least %s, got %v", e.Param(), e.Value())
+	case "max":
+		return fmt.Sprintf("must be at most %s, got %v", e.Param(), e.Value())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s, got %v", e.Param(), e.Value())
+	case "ltefield":
+		field, _ := reflect.TypeOf(Cfg{}).FieldByName(e.Param())
+		return fmt.Sprintf("must not be greater than %s", field.Tag.Get("env"))
+	case "url":
+		return fmt.Sprintf("must be a URL, got %q", e.Value())
+	}
+	return fmt.Sprintf("fails %s=%s", e.Tag(), e.Param())
 }
@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "<redacted>"
+
+// Print writes cfg as a YAML config file, secrets that are set are replaced
+// so the output is safe to share.
+func (c *Cfg) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+
+		var value any = v.Field(i).Interface()
+		switch {
+		case f.Tag.Get("secret") == "true" && !v.Field(i).IsZero():
+			value = redacted
+		case f.Type == durationType:
+			value = value.(time.Duration).String()
+		}
+
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: strings.ToLower(f.Tag.Get("env"))},
+			node,
+		)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -1,7 +1,25 @@
 package dto
 
 type (
+	// ResUpFile maps a variant name (thumbnail, medium, original) to its URL
 	ResUpFile struct {
-		ImageUrl string `json:"imageUrl"`
+		MediaID   string            `json:"mediaId"`
+		ImageURLs map[string]string `json:"imageUrls"`
+		BlurHash  string            `json:"blurHash"`
+		Color     string            `json:"color"`
+	}
+	ReqPresign struct {
+		ContentType   string `json:"contentType"`
+		ContentLength int64  `json:"contentLength"`
+	}
+	ResPresign struct {
+		Key       string            `json:"key"`
+		UploadURL string            `json:"uploadUrl"`
+		Method    string            `json:"method"`
+		Headers   map[string]string `json:"headers"`
+		ExpiresAt string            `json:"expiresAt"`
+	}
+	ReqCompleteUpload struct {
+		Key string `json:"key"`
 	}
 )
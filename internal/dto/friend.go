@@ -16,10 +16,12 @@ type (
 		Search     string `json:"search"`
 	}
 	ResGetFriends struct {
-		UserID      string `json:"userId"`
-		Name        string `json:"name"`
-		ImageURL    string `json:"imageUrl"`
-		FriendCount int    `json:"friendCount"`
-		CreatedAt   string `json:"createdAt"`
+		UserID        string `json:"userId"`
+		Name          string `json:"name"`
+		ImageURL      string `json:"imageUrl"`
+		ImageBlurHash string `json:"imageBlurHash"`
+		ImageColor    string `json:"imageColor"`
+		FriendCount   int    `json:"friendCount"`
+		CreatedAt     string `json:"createdAt"`
 	}
 )
@@ -0,0 +1,9 @@
+package dto
+
+type (
+	// ResHealth maps every check to "ok" or the reason it failed
+	ResHealth struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+)
@@ -0,0 +1,17 @@
+package dto
+
+type (
+	ParamGetNotifications struct {
+		Limit  int `json:"limit" validate:"min=1,max=100"`
+		Offset int `json:"offset" validate:"min=0"`
+	}
+	ResGetNotification struct {
+		NotificationID int            `json:"notificationId"`
+		Type           string         `json:"type"`
+		PostID         string         `json:"postId"`
+		PostText       string         `json:"postText"`
+		CommentID      *int           `json:"commentId,omitempty"`
+		Actor          ResPostCreator `json:"actor"`
+		CreatedAt      string         `json:"createdAt"`
+	}
+)
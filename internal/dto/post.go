@@ -2,11 +2,82 @@ package dto
 
 type (
 	ReqAddPost struct {
-		PostInHTML string   `json:"postInHtml" validate:"required,min=2,max=500"`
-		Tags       []string `json:"tags" validate:"required,min=1,dive,required"`
+		PostInHTML  string          `json:"postInHtml" validate:"required,min=2,max=500"`
+		Tags        []string        `json:"tags" validate:"required,min=1,dive,required"`
+		Attachments []ReqAttachment `json:"attachments" validate:"max=4,dive"`
+	}
+	// ReqAttachment is shown in the order it is sent
+	ReqAttachment struct {
+		MediaID string `json:"mediaId" validate:"required,uuid4"`
+		AltText string `json:"altText" validate:"max=200"`
 	}
 	ReqAddComment struct {
-		PostID  string `json:"postId" validate:"required,uuid4"`
-		Comment string `json:"comment" validate:"required,min=2,max=500"`
+		PostID          string `json:"postId" validate:"required,uuid4"`
+		Comment         string `json:"comment" validate:"required,min=2,max=500"`
+		ParentCommentID *int   `json:"parentCommentId" validate:"omitempty,min=1"`
+	}
+	ParamGetReplies struct {
+		Limit  int `json:"limit" validate:"min=1,max=100"`
+		Offset int `json:"offset" validate:"min=0"`
+	}
+	ResGetComment struct {
+		CommentID       int            `json:"commentId"`
+		Comment         string         `json:"comment"`
+		ParentCommentID *int           `json:"parentCommentId"`
+		Depth           int            `json:"depth"`
+		ReplyCount      int            `json:"replyCount"`
+		Mentions        []ResMention   `json:"mentions"`
+		Reactions       map[string]int `json:"reactions"`
+		Creator         ResPostCreator `json:"creator"`
+		CreatedAt       string         `json:"createdAt"`
+	}
+	ParamGetPosts struct {
+		Limit  int    `json:"limit" validate:"min=1,max=100"`
+		Offset int    `json:"offset" validate:"min=0"`
+		Tag    string `json:"tag"`
+	}
+	ParamSearchPosts struct {
+		Query  string `json:"q" validate:"required,max=100"`
+		Limit  int    `json:"limit" validate:"min=1,max=100"`
+		Offset int    `json:"offset" validate:"min=0"`
+	}
+	ResSearchPost struct {
+		ResGetPost
+		Headline string  `json:"headline"`
+		Rank     float32 `json:"rank"`
+	}
+	ResGetPost struct {
+		PostID    string         `json:"postId"`
+		Post      ResPost        `json:"post"`
+		Reactions map[string]int `json:"reactions"`
+		Creator   ResPostCreator `json:"creator"`
+	}
+	ResPost struct {
+		PostInHTML  string          `json:"postInHtml"`
+		Tags        []string        `json:"tags"`
+		Mentions    []ResMention    `json:"mentions"`
+		Attachments []ResAttachment `json:"attachments"`
+		CreatedAt   string          `json:"createdAt"`
+	}
+	ResAttachment struct {
+		MediaID   string            `json:"mediaId"`
+		AltText   string            `json:"altText"`
+		Position  int               `json:"position"`
+		ImageURLs map[string]string `json:"imageUrls"`
+		BlurHash  string            `json:"blurHash"`
+		Color     string            `json:"color"`
+	}
+	ResMention struct {
+		UserID string `json:"userId"`
+		Name   string `json:"name"`
+		Start  int    `json:"start"`
+		End    int    `json:"end"`
+	}
+	ResPostCreator struct {
+		UserID        string `json:"userId"`
+		Name          string `json:"name"`
+		ImageURL      string `json:"imageUrl"`
+		ImageBlurHash string `json:"imageBlurHash"`
+		ImageColor    string `json:"imageColor"`
 	}
 )
@@ -0,0 +1,30 @@
+package dto
+
+type (
+	ReqReactPost struct {
+		PostID   string `json:"postId" validate:"required,uuid4"`
+		Reaction string `json:"reaction" validate:"required,oneof=like love haha wow sad angry"`
+	}
+	ReqReactComment struct {
+		CommentID int    `json:"commentId" validate:"required,min=1"`
+		Reaction  string `json:"reaction" validate:"required,oneof=like love haha wow sad angry"`
+	}
+	ResReact struct {
+		Reacted  bool   `json:"reacted"`
+		Reaction string `json:"reaction,omitempty"`
+	}
+	ParamGetReactions struct {
+		Limit    int    `json:"limit" validate:"min=1,max=100"`
+		Offset   int    `json:"offset" validate:"min=0"`
+		Reaction string `json:"reaction" validate:"omitempty,oneof=like love haha wow sad angry"`
+	}
+	ResGetReactions struct {
+		UserID        string `json:"userId"`
+		Name          string `json:"name"`
+		ImageURL      string `json:"imageUrl"`
+		ImageBlurHash string `json:"imageBlurHash"`
+		ImageColor    string `json:"imageColor"`
+		Reaction      string `json:"reaction"`
+		CreatedAt     string `json:"createdAt"`
+	}
+)
@@ -0,0 +1,8 @@
+package dto
+
+type (
+	ResTrendingTag struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+)
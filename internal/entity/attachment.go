@@ -0,0 +1,8 @@
+package entity
+
+type Attachment struct {
+	PostID   string `json:"post_id"`  // UUID
+	MediaID  string `json:"media_id"` // UUID
+	Position int    `json:"position"`
+	AltText  string `json:"alt_text"`
+}
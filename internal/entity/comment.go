@@ -1,8 +1,11 @@
 package entity
 
 type Comment struct {
-	ID      int    `json:"id"`
-	PostID  string `json:"post_id"` // UUID
-	Comment string `json:"comment"`
-	UserID  string `json:"user_id"` // UUID
+	ID              int    `json:"id"`
+	PostID          string `json:"post_id"` // UUID
+	Comment         string `json:"comment"`
+	UserID          string `json:"user_id"`           // UUID
+	ParentCommentID *int   `json:"parent_comment_id"` // nil for top level comments
+	Depth           int    `json:"depth"`
+	ReplyCount      int    `json:"reply_count"`
 }
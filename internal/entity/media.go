@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+type Media struct {
+	ID       string            `json:"id"`    // UUID, shared by the keys of every variant
+	Owner    string            `json:"owner"` // UUID, empty once the owner is deleted
+	Key      string            `json:"key"`   // key of the original variant
+	Size     int64             `json:"size"`  // bytes of all variants together
+	Hash     string            `json:"hash"`  // hex SHA-256 of the uploaded bytes
+	MimeType string            `json:"mime"`
+	Variants map[string]string `json:"variants"` // variant name to key
+	BlurHash string            `json:"blur_hash"`
+	Color    string            `json:"color"` // dominant color as #rrggbb
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+type Mention struct {
+	ID        int    `json:"id"`
+	PostID    string `json:"post_id"`    // UUID
+	CommentID *int   `json:"comment_id"` // nil when mentioned in the post itself
+	UserID    string `json:"user_id"`    // UUID
+	StartAt   int    `json:"start_at"`
+	EndAt     int    `json:"end_at"`
+}
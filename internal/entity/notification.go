@@ -0,0 +1,12 @@
+package entity
+
+const NotificationTypeMention = "mention"
+
+type Notification struct {
+	ID        int    `json:"id"`
+	UserID    string `json:"user_id"`  // UUID
+	ActorID   string `json:"actor_id"` // UUID
+	Type      string `json:"type"`
+	PostID    string `json:"post_id"` // UUID
+	CommentID *int   `json:"comment_id"`
+}
@@ -1,7 +1,8 @@
 package entity
 
 type Post struct {
-	ID      string `json:"id"`
-	Content string `json:"content"`
-	Creator string `json:"creator"` // UUID
+	ID          string `json:"id"`
+	Content     string `json:"content"`
+	ContentText string `json:"content_text"` // plain text projection of Content
+	Creator     string `json:"creator"`      // UUID
 }
@@ -0,0 +1,7 @@
+package entity
+
+type Reaction struct {
+	TargetID string `json:"target_id"` // post UUID or comment ID
+	UserID   string `json:"user_id"`   // UUID
+	Reaction string `json:"reaction"`
+}
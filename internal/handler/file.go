@@ -1,79 +1,128 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/google/uuid"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	"github.com/vandenbill/social-media-10k-rps/pkg/media"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
 )
 
 type fileHandler struct {
-	cfg *cfg.Cfg
+	cfg      *cfg.Cfg
+	storage  storage.Storage
+	mediaSvc *service.MediaService
 }
 
-func newFileHandler(cfg *cfg.Cfg) *fileHandler {
-	return &fileHandler{cfg}
+func newFileHandler(cfg *cfg.Cfg, storage storage.Storage, mediaSvc *service.MediaService) *fileHandler {
+	return &fileHandler{cfg, storage, mediaSvc}
 }
+
 func (h *fileHandler) Upload(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(2 << 20)
+	// leave room for the multipart envelope on top of the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.UploadMaxBytes+1<<20)
+	err := r.ParseMultipartForm(h.cfg.UploadMaxBytes)
 	if err != nil {
 		http.Error(w, "Failed to parse multipart form", http.StatusBadRequest)
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
+	file, _, err := r.FormFile("file")
 	if err != nil {
 		http.Error(w, "Failed to get fromFile form data", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	ext := strings.ToLower(filepath.Ext(handler.Filename))
-	if ext != ".jpg" && ext != ".jpeg" {
-		http.Error(w, "File must be in JPG or JPEG format", http.StatusBadRequest)
+	data, err := io.ReadAll(io.LimitReader(file, h.cfg.UploadMaxBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.mediaSvc.Upload(r.Context(), data, token.Subject())
+	if err != nil {
+		code, msg := h.translateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *fileHandler) Presign(w http.ResponseWriter, r *http.Request) {
+	var req dto.ReqPresign
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
 		return
 	}
 
-	if handler.Size < 10*1024 || handler.Size > 2*1024*1024 { // 10 KB to 2 MB
-		http.Error(w, "File size must be between 10 KB and 2 MB", http.StatusBadRequest)
+	res, err := h.mediaSvc.Presign(r.Context(), req, token.Subject())
+	if err != nil {
+		code, msg := h.translateError(r.Context(), err)
+		http.Error(w, msg, code)
 		return
 	}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(h.cfg.S3Region),
-		Credentials: credentials.NewStaticCredentials(h.cfg.S3ID, h.cfg.S3SecretKey, ""),
-	})
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
 	if err != nil {
-		http.Error(w, "Failed to create AWS session", http.StatusInternalServerError)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
 		return
 	}
+}
 
-	svc := s3.New(sess)
+func (h *fileHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	var req dto.ReqCompleteUpload
 
-	fileName := fmt.Sprintf("%s%s", uuid.NewString(), ext)
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "failed to parse request body", http.StatusBadRequest)
+		return
+	}
 
-	_, err = svc.PutObject(&s3.PutObjectInput{
-		Bucket: aws.String(h.cfg.S3BucketName),
-		Key:    aws.String(fileName),
-		ACL:    aws.String("public-read"),
-		Body:   file,
-	})
+	token, _, err := jwtauth.FromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to upload file to S3", http.StatusInternalServerError)
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
 		return
 	}
 
-	res := dto.ResUpFile{
-		ImageUrl: fmt.Sprintf("https://%s.s3.amazonaws.com/%s", h.cfg.S3BucketName, fileName),
+	res, err := h.mediaSvc.Complete(r.Context(), req, token.Subject())
+	if err != nil {
+		code, msg := h.translateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -83,3 +132,43 @@ func (h *fileHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// Serve streams an uploaded object back, this is how the local storage
+// backend exposes its files.
+func (h *fileHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	body, err := h.storage.Get(r.Context(), key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "image not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to get image", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	io.Copy(w, body)
+}
+
+// translateError explains rejected uploads, anything else goes through ierr.
+func (h *fileHandler) translateError(ctx context.Context, err error) (int, string) {
+	switch err {
+	case media.ErrTooSmall, media.ErrTooLarge:
+		return http.StatusBadRequest, fmt.Sprintf("File size must be between %d KB and %d KB", h.cfg.UploadMinBytes/1024, h.cfg.UploadMaxBytes/1024)
+	case media.ErrUnsupportedType:
+		return http.StatusBadRequest, fmt.Sprintf("File must be one of %s", strings.Join(h.cfg.UploadAllowedTypes, ", "))
+	case media.ErrDimensions:
+		return http.StatusBadRequest, fmt.Sprintf("Image must be at most %dx%d pixels", h.cfg.UploadMaxWidth, h.cfg.UploadMaxHeight)
+	case media.ErrMalformed:
+		return http.StatusBadRequest, "File is not a valid image"
+	case storage.ErrPresignUnsupported:
+		return http.StatusNotImplemented, err.Error()
+	}
+	return ierr.TranslateError(ctx, err)
+}
@@ -37,7 +37,7 @@ func (h *friendHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
 
 	err = h.friendSvc.AddFriend(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -62,7 +62,7 @@ func (h *friendHandler) DeleteFriend(w http.ResponseWriter, r *http.Request) {
 
 	err = h.friendSvc.DeleteFriend(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -89,7 +89,7 @@ func (h *friendHandler) GetFriends(w http.ResponseWriter, r *http.Request) {
 
 	res, meta, err := h.friendSvc.GetFriends(r.Context(), param, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
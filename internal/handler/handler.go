@@ -1,41 +1,35 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
 	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// var (
-// 	requestsTotal = prometheus.NewCounterVec(
-// 		prometheus.CounterOpts{
-// 			Name: "http_requests_total",
-// 			Help: "Total number of HTTP requests.",
-// 		},
-// 		[]string{"method", "path", "status"},
-// 	)
-// 	requestDuration = prometheus.NewHistogramVec(
-// 		prometheus.HistogramOpts{
-// 			Name:    "http_request_duration_seconds",
-// 			Help:    "Histogram of request duration in seconds.",
-// 			Buckets: prometheus.DefBuckets,
-// 		},
-// 		[]string{"method", "path", "status"},
-// 	)
-// )
-
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	size       int
 }
 
 func newResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{w, http.StatusOK}
+	return &responseWriter{w, http.StatusOK, 0}
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -43,50 +37,71 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
 func (rw *responseWriter) Status() int {
 	return rw.statusCode
 }
 
+func (rw *responseWriter) Size() int {
+	return rw.size
+}
+
 type Handler struct {
 	router  *chi.Mux
 	service *service.Service
+	storage storage.Storage
 	cfg     *cfg.Cfg
 }
 
-func NewHandler(router *chi.Mux, service *service.Service, cfg *cfg.Cfg) *Handler {
-	handler := &Handler{router, service, cfg}
+func NewHandler(router *chi.Mux, service *service.Service, storage storage.Storage, cfg *cfg.Cfg) *Handler {
+	handler := &Handler{router, service, storage, cfg}
 	handler.registRoute()
 
 	return handler
 }
 
 func (h *Handler) registRoute() {
-	// prometheus.MustRegister(requestsTotal, requestDuration)
-
 	r := h.router
 	var tokenAuth *jwtauth.JWTAuth = jwtauth.New("HS256", []byte(h.cfg.JWTSecret), nil, jwt.WithAcceptableSkew(30*time.Second))
 
 	userH := newUserHandler(h.service.User)
-	fileH := newFileHandler(h.cfg)
+	fileH := newFileHandler(h.cfg, h.storage, h.service.Media)
 	friendH := newFriendHandler(h.service.Friend)
 	postH := newPostHandler(h.service.Post)
+	reactionH := newReactionHandler(h.service.Reaction)
+	notificationH := newNotificationHandler(h.service.Notification)
+	tagH := newTagHandler(h.service.Tag)
+	healthH := newHealthHandler(h.service.Health)
 
 	// r.Use(middleware.RedirectSlashes)
-	// r.Use(prometheusMiddleware)
+	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(accessLogMiddleware(&logging.Sampler{Initial: h.cfg.LogSampleInitial, Thereafter: h.cfg.LogSampleThereafter}))
+	r.Use(prometheusMiddleware)
 
-	// r.Get("/metrics", func(h http.Handler) http.HandlerFunc {
-	// 	return func(w http.ResponseWriter, r *http.Request) {
-	// 		h.ServeHTTP(w, r)
-	// 	}
-	// }(promhttp.Handler()))
+	r.Get("/healthz", healthH.Healthz)
+	r.Get("/readyz", healthH.Readyz)
+	r.Get("/startupz", healthH.Startupz)
 
 	r.Post("/v1/user/register", userH.Register)
 	r.Post("/v1/user/login", userH.Login)
 
+	// with s3 the bucket serves the media itself, through this route anyone
+	// could read any key of it, pending uploads included
+	if h.cfg.StorageBackend == "local" {
+		r.Get("/v1/image/{key}", fileH.Serve)
+	}
+
 	// protected route
 	r.Group(func(r chi.Router) {
 		r.Use(jwtauth.Verifier(tokenAuth))
 		r.Use(jwtauth.Authenticator(tokenAuth))
+		r.Use(subLogMiddleware)
 
 		r.Post("/v1/user/link", userH.LinkEmail)
 		r.Post("/v1/user/link/phone", userH.LinkPhone)
@@ -96,24 +111,142 @@ func (h *Handler) registRoute() {
 		r.Post("/v1/friend", friendH.AddFriend)
 		r.Delete("/v1/friend", friendH.DeleteFriend)
 
+		r.Get("/v1/post", postH.GetPosts)
+		r.Get("/v1/post/search", postH.SearchPosts)
 		r.Post("/v1/post", postH.AddPost)
+		r.Delete("/v1/post/{id}", postH.DeletePost)
 
 		r.Post("/v1/post/comment", postH.AddComment)
+		r.Get("/v1/post/comment/{id}/replies", postH.GetReplies)
+
+		r.Post("/v1/post/reaction", reactionH.ReactPost)
+		r.Post("/v1/post/comment/reaction", reactionH.ReactComment)
+		r.Get("/v1/post/{id}/reactions", reactionH.GetPostReactions)
+
+		r.Get("/v1/tag/trending", tagH.GetTrending)
+		r.Get("/v1/tag/{tag}/posts", tagH.GetTagPosts)
+
+		r.Get("/v1/notification", notificationH.GetNotifications)
 
 		r.Post("/v1/image", fileH.Upload)
+		r.Post("/v1/image/presign", fileH.Presign)
+		r.Post("/v1/image/complete", fileH.Complete)
 	})
 }
 
-// func prometheusMiddleware(next http.Handler) http.Handler {
-// 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		startTime := time.Now()
-// 		rw := newResponseWriter(w)
-// 		defer func() {
-// 			status := rw.Status()
-// 			duration := time.Since(startTime).Seconds()
-// 			requestsTotal.WithLabelValues(r.Method, r.URL.Path, strconv.Itoa(status)).Inc()
-// 			requestDuration.WithLabelValues(r.Method, r.URL.Path, strconv.Itoa(status)).Observe(duration)
-// 		}()
-// 		next.ServeHTTP(rw, r)
-// 	})
-// }
+// requestIDMiddleware keeps the X-Request-ID the caller sent or makes one up,
+// echoes it back and attaches it to every log of the request.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := logging.NewContext(r.Context(), slog.String("request_id", requestID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// subLogMiddleware runs after authentication and attaches the user to every
+// log of the request, the access log included.
+func subLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err == nil {
+			logging.AddAttrs(r.Context(), slog.String("sub", token.Subject()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// accessLogMiddleware logs every request once it is served. Client and server
+// errors are always logged, successful requests go through sampler.
+func accessLogMiddleware(sampler *logging.Sampler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startTime := time.Now()
+			rw := newResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			status := rw.Status()
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			default:
+				if !sampler.Allow() {
+					return
+				}
+			}
+
+			slog.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", chi.RouteContext(r.Context()).RoutePattern()),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("size", rw.Size()),
+				slog.Duration("duration", time.Since(startTime)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
+
+var tracer = otel.Tracer("github.com/vandenbill/social-media-10k-rps/internal/handler")
+
+// tracingMiddleware starts the server span of a request, continuing the trace
+// of the caller when it sent W3C trace context. The span is renamed to the
+// route pattern once the router has matched it.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.Status()))
+		if rw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.Status()))
+		}
+	})
+}
+
+// prometheusMiddleware records every request under its chi route pattern,
+// which is only known once the router has matched it. Requests no route
+// matched share the "unmatched" label.
+func prometheusMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		rw := newResponseWriter(w)
+		metrics.HTTPRequestsInFlight.Inc()
+		defer func() {
+			metrics.HTTPRequestsInFlight.Dec()
+
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(rw.Status())
+			duration := time.Since(startTime).Seconds()
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).Observe(duration)
+			metrics.HTTPResponseSize.WithLabelValues(r.Method, route, status).Observe(float64(rw.Size()))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/service"
+)
+
+type healthHandler struct {
+	healthSvc *service.HealthService
+}
+
+func newHealthHandler(healthSvc *service.HealthService) *healthHandler {
+	return &healthHandler{healthSvc}
+}
+
+// Healthz only tells the process is up and serving, it checks nothing else so
+// a database outage doesn't get every instance restarted.
+func (h *healthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, dto.ResHealth{Status: "ok"}, true)
+}
+
+func (h *healthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.healthSvc.Ready(r.Context())
+	writeHealth(w, res, ok)
+}
+
+func (h *healthHandler) Startupz(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.healthSvc.Started(r.Context())
+	writeHealth(w, res, ok)
+}
+
+func writeHealth(w http.ResponseWriter, res dto.ResHealth, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type notificationHandler struct {
+	notificationSvc *service.NotificationService
+}
+
+func newNotificationHandler(notificationSvc *service.NotificationService) *notificationHandler {
+	return &notificationHandler{notificationSvc}
+}
+
+func (h *notificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamGetNotifications
+
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.notificationSvc.GetNotifications(r.Context(), param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Get notifications successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
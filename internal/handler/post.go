@@ -3,11 +3,14 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/vandenbill/social-media-10k-rps/internal/dto"
 	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
 	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
 )
 
 type postHandler struct {
@@ -35,7 +38,7 @@ func (h *postHandler) AddPost(w http.ResponseWriter, r *http.Request) {
 
 	err = h.postSvc.AddPost(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -60,7 +63,130 @@ func (h *postHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 
 	err = h.postSvc.AddComment(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *postHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamGetPosts
+
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.postSvc.GetPosts(r.Context(), param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Get posts successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *postHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamGetReplies
+
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, ierr.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.postSvc.GetReplies(r.Context(), commentID, param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Get replies successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *postHandler) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamSearchPosts
+
+	param.Query = queryParams.Get("q")
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.postSvc.SearchPosts(r.Context(), param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Search posts successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *postHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	err = h.postSvc.DeletePost(r.Context(), chi.URLParam(r, "id"), token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type reactionHandler struct {
+	reactionSvc *service.ReactionService
+}
+
+func newReactionHandler(reactionSvc *service.ReactionService) *reactionHandler {
+	return &reactionHandler{reactionSvc}
+}
+
+func (h *reactionHandler) ReactPost(w http.ResponseWriter, r *http.Request) {
+	var req dto.ReqReactPost
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.reactionSvc.ReactPost(r.Context(), req, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessReponse{}
+	successRes.Message = "Reaction toggled successfully"
+	successRes.Data = res
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *reactionHandler) ReactComment(w http.ResponseWriter, r *http.Request) {
+	var req dto.ReqReactComment
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.reactionSvc.ReactComment(r.Context(), req, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessReponse{}
+	successRes.Message = "Reaction toggled successfully"
+	successRes.Data = res
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *reactionHandler) GetPostReactions(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamGetReactions
+
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+	param.Reaction = queryParams.Get("reaction")
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.reactionSvc.GetPostReactions(r.Context(), chi.URLParam(r, "id"), param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Get reactions successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type tagHandler struct {
+	tagSvc *service.TagService
+}
+
+func newTagHandler(tagSvc *service.TagService) *tagHandler {
+	return &tagHandler{tagSvc}
+}
+
+func (h *tagHandler) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var param dto.ParamGetPosts
+
+	param.Limit, _ = strconv.Atoi(queryParams.Get("limit"))
+	param.Offset, _ = strconv.Atoi(queryParams.Get("offset"))
+
+	tag, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		http.Error(w, "failed to parse tag", http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get token from request", http.StatusBadRequest)
+		return
+	}
+
+	res, meta, err := h.tagSvc.GetTagPosts(r.Context(), tag, param, token.Subject())
+	if err != nil {
+		code, msg := ierr.TranslateError(r.Context(), err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	successRes := response.SuccessPageReponse{}
+	successRes.Message = "Get tag posts successfully"
+	successRes.Data = res
+	successRes.Meta = meta
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *tagHandler) GetTrending(w http.ResponseWriter, r *http.Request) {
+	successRes := response.SuccessReponse{}
+	successRes.Message = "Get trending tags successfully"
+	successRes.Data = h.tagSvc.GetTrending()
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(successRes)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
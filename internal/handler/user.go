@@ -30,7 +30,7 @@ func (h *userHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.userSvc.Register(r.Context(), req)
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -59,7 +59,7 @@ func (h *userHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.userSvc.Login(r.Context(), req)
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -93,7 +93,7 @@ func (h *userHandler) LinkEmail(w http.ResponseWriter, r *http.Request) {
 
 	err = h.userSvc.LinkEmail(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -118,7 +118,7 @@ func (h *userHandler) LinkPhone(w http.ResponseWriter, r *http.Request) {
 
 	err = h.userSvc.LinkPhone(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -143,7 +143,7 @@ func (h *userHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 
 	err = h.userSvc.UpdateAccount(r.Context(), req, token.Subject())
 	if err != nil {
-		code, msg := ierr.TranslateError(err)
+		code, msg := ierr.TranslateError(r.Context(), err)
 		http.Error(w, msg, code)
 		return
 	}
@@ -1,11 +1,13 @@
 package ierr
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/pkg/errors"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
 )
 
 type customError struct {
@@ -22,16 +24,36 @@ func ExtendErr(err customError, msg string) error {
 }
 
 var (
-	ErrInternal   = customError{Message: "Sorry, an internal server error occurred. Please try again later."}
-	ErrDuplicate  = customError{Message: "The data you provided conflicts with existing data. Please review the information you entered"}
-	ErrNotFound   = customError{Message: "Sorry, the resource you requested could not be found."}
-	ErrBadRequest = customError{Message: "Sorry, the request is invalid. Please check your input and try again."}
-	ErrForbidden  = customError{Message: "You do not have permission to access or edit this resource."}
+	ErrInternal      = customError{Message: "Sorry, an internal server error occurred. Please try again later."}
+	ErrDuplicate     = customError{Message: "The data you provided conflicts with existing data. Please review the information you entered"}
+	ErrNotFound      = customError{Message: "Sorry, the resource you requested could not be found."}
+	ErrBadRequest    = customError{Message: "Sorry, the request is invalid. Please check your input and try again."}
+	ErrForbidden     = customError{Message: "You do not have permission to access or edit this resource."}
+	ErrQuotaExceeded = customError{Message: "You have used up your storage quota. Please remove some uploads and try again."}
 )
 
-func TranslateError(err error) (code int, msg string) {
-	log.Println(err)
-	
+// WithStack records where err left our code so the log shows it, errors of
+// ours are compared by value and returned as they are.
+func WithStack(err error) error {
+	if _, ok := err.(customError); ok {
+		return err
+	}
+	if _, ok := err.(interface{ StackTrace() errors.StackTrace }); ok {
+		return err
+	}
+	return errors.WithStack(err)
+}
+
+// TranslateError maps err to a status code and the message shown to the
+// client. Errors that aren't one of ours are internal and logged with their
+// cause and stack, the rest only at debug level.
+func TranslateError(ctx context.Context, err error) (code int, msg string) {
+	if _, ok := errors.Cause(err).(customError); !ok {
+		slog.ErrorContext(ctx, "internal error", logging.Error(err))
+	} else {
+		slog.DebugContext(ctx, "request rejected", slog.String("reason", err.Error()))
+	}
+
 	switch errors.Cause(err) {
 	case ErrDuplicate:
 		return http.StatusConflict, err.Error()
@@ -41,6 +63,8 @@ func TranslateError(err error) (code int, msg string) {
 		return http.StatusForbidden, err.Error()
 	case ErrBadRequest:
 		return http.StatusBadRequest, err.Error()
+	case ErrQuotaExceeded:
+		return http.StatusRequestEntityTooLarge, err.Error()
 	}
 
 	return http.StatusInternalServerError, ErrInternal.Message
@@ -0,0 +1,31 @@
+package ierr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestWithStack(t *testing.T) {
+	if err := WithStack(nil); err != nil {
+		t.Fatalf("WithStack(nil) = %v, want nil", err)
+	}
+
+	// services compare our errors by value
+	if err := WithStack(ErrNotFound); err != ErrNotFound {
+		t.Errorf("WithStack(ErrNotFound) = %#v, want it unchanged", err)
+	}
+
+	cause := fmt.Errorf("connection reset")
+	err := WithStack(cause)
+	if _, ok := err.(interface{ StackTrace() errors.StackTrace }); !ok {
+		t.Fatalf("WithStack(%v) has no stack trace", cause)
+	}
+	if errors.Cause(err) != cause {
+		t.Errorf("Cause() = %v, want %v", errors.Cause(err), cause)
+	}
+	if again := WithStack(err); again != err {
+		t.Errorf("WithStack wrapped an error that already has a stack")
+	}
+}
@@ -0,0 +1,112 @@
+// Package logging sets up log/slog to write JSON and carries per request
+// attributes, such as the request ID and the user, in the context so every
+// record logged with that context gets them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup makes a JSON handler writing to stdout the default logger, the
+// standard log package goes through it too. level is debug, info, warn or
+// error.
+func Setup(level string) error {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+
+	h := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+type ctxKey struct{}
+
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// NewContext returns a copy of ctx that collects attributes added with
+// AddAttrs, including by handlers further down the chain.
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &fields{attrs: attrs})
+}
+
+// AddAttrs attaches attrs to every record logged with ctx from now on, it
+// does nothing if ctx didn't come from NewContext.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	f, ok := ctx.Value(ctxKey{}).(*fields)
+	if !ok {
+		return
+	}
+
+	f.mu.Lock()
+	f.attrs = append(f.attrs, attrs...)
+	f.mu.Unlock()
+}
+
+// contextHandler adds the attributes of the context and the current trace ID.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, ok := ctx.Value(ctxKey{}).(*fields); ok {
+		f.mu.Lock()
+		r.AddAttrs(f.attrs...)
+		f.mu.Unlock()
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// Error logs err as a group with its message, the root cause when it was
+// wrapped and the stack trace recorded by pkg/errors, if any.
+func Error(err error) slog.Attr {
+	attrs := []any{slog.String("message", err.Error())}
+
+	var stack stackTracer
+	for e := err; e != nil; {
+		if s, ok := e.(stackTracer); ok {
+			stack = s // keep the deepest, it's closest to where things failed
+		}
+		c, ok := e.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		e = c.Cause()
+	}
+
+	if cause := errors.Cause(err); cause != err {
+		attrs = append(attrs, slog.String("cause", cause.Error()))
+	}
+	if stack != nil {
+		attrs = append(attrs, slog.String("stack", fmt.Sprintf("%+v", stack.StackTrace())))
+	}
+
+	return slog.Group("error", attrs...)
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler thins out logs under load: in every second the first Initial calls
+// to Allow pass, after that only every Thereafter-th one does. Thereafter 0
+// drops everything past Initial.
+type Sampler struct {
+	Initial    int
+	Thereafter int
+
+	mu     sync.Mutex
+	second int64
+	count  int
+}
+
+func (s *Sampler) Allow() bool {
+	now := time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now != s.second {
+		s.second = now
+		s.count = 0
+	}
+	s.count++
+
+	if s.count <= s.Initial {
+		return true
+	}
+	return s.Thereafter > 0 && (s.count-s.Initial)%s.Thereafter == 0
+}
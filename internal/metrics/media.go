@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	MediaGCRuns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "media_gc_runs_total",
+			Help: "Total number of orphaned media collection runs.",
+		},
+		[]string{"result"},
+	)
+	MediaGCDeleted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "media_gc_deleted_total",
+			Help: "Total number of orphaned media deleted, dry runs count what would have been deleted.",
+		},
+		[]string{"dry_run"},
+	)
+	MediaGCReclaimedBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "media_gc_reclaimed_bytes_total",
+			Help: "Total bytes of orphaned media removed from storage.",
+		},
+		[]string{"dry_run"},
+	)
+)
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads the pool statistics on every scrape instead of keeping
+// gauges up to date.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquires        *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+// RegisterPool exposes the statistics of pool as pgxpool_* metrics.
+func RegisterPool(pool *pgxpool.Pool) {
+	prometheus.MustRegister(NewPoolCollector(pool))
+}
+
+// NewPoolCollector returns the collector RegisterPool registers. The pool is
+// only read on Collect.
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	return &poolCollector{
+		pool:            pool,
+		acquiredConns:   prometheus.NewDesc("pgxpool_acquired_conns", "Number of connections currently acquired from the pool.", nil, nil),
+		idleConns:       prometheus.NewDesc("pgxpool_idle_conns", "Number of idle connections in the pool.", nil, nil),
+		totalConns:      prometheus.NewDesc("pgxpool_total_conns", "Total number of connections in the pool.", nil, nil),
+		maxConns:        prometheus.NewDesc("pgxpool_max_conns", "Maximum size of the pool.", nil, nil),
+		acquires:        prometheus.NewDesc("pgxpool_acquires_total", "Total number of successful acquires from the pool.", nil, nil),
+		emptyAcquires:   prometheus.NewDesc("pgxpool_empty_acquires_total", "Total number of acquires that had to wait for a connection.", nil, nil),
+		canceledAcquire: prometheus.NewDesc("pgxpool_canceled_acquires_total", "Total number of acquires canceled by their context.", nil, nil),
+		acquireDuration: prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Total time spent waiting for successful acquires.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquires
+	ch <- c.emptyAcquires
+	ch <- c.canceledAcquire
+	ch <- c.acquireDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquire, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
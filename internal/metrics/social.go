@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Domain events, recorded by the services so they count the same whatever
+// transport the request came through.
+var (
+	Registrations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_registrations_total",
+			Help: "Total number of users registered, by credential type.",
+		},
+		[]string{"credential_type"},
+	)
+	Logins = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_logins_total",
+			Help: "Total number of login attempts, failure means unknown credentials or a wrong password.",
+		},
+		[]string{"result"},
+	)
+	Friendships = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "friendships_total",
+			Help: "Total number of friendships added or removed.",
+		},
+		[]string{"action"},
+	)
+	PostsCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "posts_created_total",
+			Help: "Total number of posts created.",
+		},
+	)
+	CommentsCreated = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "comments_created_total",
+			Help: "Total number of comments and replies created.",
+		},
+	)
+	TagsPerPost = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "post_tags",
+			Help:    "Histogram of the number of tags per created post.",
+			Buckets: []float64{0, 1, 2, 3, 5, 8, 13, 21},
+		},
+	)
+	UploadBytes = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "media_upload_bytes",
+			Help:    "Histogram of the size of accepted uploads as sent by the client.",
+			Buckets: prometheus.ExponentialBuckets(16<<10, 2, 10),
+		},
+	)
+	UploadFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "media_upload_failures_total",
+			Help: "Total number of rejected or failed uploads, by reason.",
+		},
+		[]string{"reason"},
+	)
+)
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/db"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/pkg/migrate"
+	"github.com/vandenbill/social-media-10k-rps/pkg/sanitize"
+)
+
+// seedQueries fill the tables read by the hot queries with enough rows that
+// the planner prefers an index wherever one fits. Everything is rolled back.
+var seedQueries = []string{
+	`CREATE TEMP TABLE seed_users ON COMMIT DROP AS
+	SELECT gen_random_uuid() AS id, i AS n FROM generate_series(1, 20000) i`,
+
+	`INSERT INTO users (id, name, email, password)
+	SELECT id, 'user ' || n, 'user' || n || '@example.com', 'x' FROM seed_users`,
+
+	`INSERT INTO friends (a, b)
+	SELECT a.id, b.id FROM seed_users a
+		CROSS JOIN generate_series(1, 10) k
+		JOIN seed_users b ON b.n = (a.n + k) % 20000 + 1`,
+
+	`INSERT INTO posts (id, creator, content, content_text, tags_text, created_at)
+	SELECT gen_random_uuid(), u.id, '<p>post ' || k || '</p>', 'post ' || k || ' about things', 'tag' || (u.n % 100),
+		now() - random() * interval '30 days'
+	FROM seed_users u CROSS JOIN generate_series(1, 5) k`,
+
+	`INSERT INTO tags (post_id, tag)
+	SELECT p.id, p.tags_text FROM posts p JOIN seed_users u ON u.id = p.creator`,
+
+	`INSERT INTO comments (post_id, user_id, comment)
+	SELECT p.id, p.creator, 'nice post' FROM posts p JOIN seed_users u ON u.id = p.creator`,
+
+	`INSERT INTO comments (post_id, user_id, comment, parent_comment_id, depth)
+	SELECT c.post_id, c.user_id, 'thanks', c.id, 1 FROM comments c JOIN seed_users u ON u.id = c.user_id`,
+
+	`ANALYZE users, friends, posts, tags, comments`,
+}
+
+// indexedTables must never be read with a sequential scan by the hot queries.
+var indexedTables = map[string]bool{"users": true, "friends": true, "posts": true, "tags": true, "comments": true}
+
+// TestHotQueriesUseIndexes explains the queries behind the feed, search,
+// replies and friend lists against a seeded copy of the schema. It needs a
+// database it may migrate, set DATABASE_URL to run it.
+func TestHotQueriesUseIndexes(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	migrator, err := migrate.New(pool, db.Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, q := range seedQueries {
+		if _, err := tx.Exec(ctx, q); err != nil {
+			t.Fatalf("seed: %v\n%s", err, q)
+		}
+	}
+
+	var sub, friend string
+	var commentID int
+	err = tx.QueryRow(ctx, `SELECT s.id, f.b, c.id FROM seed_users s
+		JOIN friends f ON f.a = s.id
+		JOIN comments c ON c.user_id = s.id AND c.parent_comment_id IS NULL
+		WHERE s.n = 1 LIMIT 1`).Scan(&sub, &friend, &commentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	friendsQuery, friendsArgs := getFriendsQuery(dto.ParamGetFriends{
+		Limit: 10, SortBy: "friendCount", OrderBy: "desc", OnlyFriend: true, Search: "user 1",
+	}, sub)
+	usersQuery, usersArgs := getFriendsQuery(dto.ParamGetFriends{
+		Limit: 10, SortBy: "createdAt", OrderBy: "desc", Search: "user 12345",
+	}, sub)
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+	}{
+		{"IsHaveFriend", isHaveFriendQuery, []any{sub}},
+		{"FindFriend", findFriendQuery, []any{sub, friend}},
+		{"GetPosts", getPostsQuery, []any{sub, 10, 0, ""}},
+		{"GetPosts by tag", getPostsQuery, []any{sub, 10, 0, "tag7"}},
+		{"GetPosts count", countPostsQuery, []any{sub, ""}},
+		{"SearchPosts", searchPostsQuery, []any{sub, 10, 0, "things", sanitize.HeadlineStart + sanitize.HeadlineStop, sanitize.HeadlineOptions}},
+		{"SearchPosts count", countSearchPostsQuery, []any{sub, "things"}},
+		{"GetReplies", getRepliesQuery, []any{commentID, 10, 0}},
+		{"GetFriends of user", friendsQuery, friendsArgs},
+		{"GetFriends search", usersQuery, usersArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := explain(t, ctx, tx, tt.query, tt.args)
+			tables, err := seqScans(plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range tables {
+				if indexedTables[table] {
+					t.Errorf("sequential scan on %s\n%s", table, plan)
+				}
+			}
+		})
+	}
+}
+
+func explain(t *testing.T, ctx context.Context, tx pgx.Tx, q string, args []any) []byte {
+	t.Helper()
+
+	var plan []byte
+	err := tx.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+q, args...).Scan(&plan)
+	if err != nil {
+		t.Fatalf("explain: %v\n%s", err, q)
+	}
+	return plan
+}
+
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// seqScans returns the tables an EXPLAIN (FORMAT JSON) plan scans
+// sequentially, subplans included.
+func seqScans(plan []byte) ([]string, error) {
+	var root []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &root); err != nil {
+		return nil, err
+	}
+
+	var tables []string
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		if n.NodeType == "Seq Scan" {
+			tables = append(tables, n.RelationName)
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	for _, r := range root {
+		walk(r.Plan)
+	}
+	return tables, nil
+}
@@ -36,7 +36,7 @@ func (u *friendRepo) AddFriend(ctx context.Context, sub, friendSub string) error
 				return ierr.ErrDuplicate
 			}
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
@@ -48,14 +48,16 @@ func (u *friendRepo) DeleteFriend(ctx context.Context, sub, friendSub string) er
 		sub, friendSub)
 
 	if err != nil {
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
 }
 
+const findFriendQuery = `SELECT 1 FROM friends WHERE (a = $1 AND b = $2) OR (a = $2 AND b = $1)`
+
 func (u *friendRepo) FindFriend(ctx context.Context, sub, friendSub string) error {
-	q := `SELECT 1 FROM friends WHERE (a = $1 AND b = $2) OR (a = $2 AND b = $1)`
+	q := findFriendQuery
 
 	v := 0
 	err := u.conn.QueryRow(ctx, q,
@@ -65,65 +67,101 @@ func (u *friendRepo) FindFriend(ctx context.Context, sub, friendSub string) erro
 		if err.Error() == "no rows in result set" {
 			return ierr.ErrNotFound
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
 }
 
-func (u *friendRepo) GetFriends(ctx context.Context, param dto.ParamGetFriends, sub string) ([]dto.ResGetFriends, int, error) {
+// getFriendsQuery builds the GetFriends query along with its arguments. SortBy
+// and OrderBy are checked against a list by the dto and written as is.
+func getFriendsQuery(param dto.ParamGetFriends, sub string) (string, []any) {
 	var query strings.Builder
+	var args []any
 
 	if param.OnlyFriend {
-		query.WriteString(fmt.Sprintf("SELECT u.id, u.name, u.image_url, u.created_at, (select count(*) from friends f2 where f2.a = f.b) as friendCount from friends f join users u on u.id = f.b WHERE f.a = '%s' ", sub))
+		args = append(args, sub)
+		query.WriteString(fmt.Sprintf("SELECT u.id, u.name, u.image_url, %s, u.created_at, (select count(*) from friends f2 where f2.a = f.b) as friendCount from friends f join users u on u.id = f.b %s WHERE f.a = $%d ", avatarColumns, avatarJoin, len(args)))
 	} else {
-		query.WriteString("SELECT u.id, u.name, u.image_url, u.created_at, (SELECT COUNT(*) FROM friends f WHERE f.a = u.id) as friendCount FROM users u WHERE 1 = 1 ")
+		query.WriteString(fmt.Sprintf("SELECT u.id, u.name, u.image_url, %s, u.created_at, (SELECT COUNT(*) FROM friends f WHERE f.a = u.id) as friendCount FROM users u %s WHERE 1 = 1 ", avatarColumns, avatarJoin))
 	}
 
 	if param.Search != "" {
-		query.WriteString(fmt.Sprintf("AND LOWER(name) LIKE LOWER('%s') ", fmt.Sprintf("%%%s%%", param.Search)))
+		args = append(args, "%"+param.Search+"%")
+		query.WriteString(fmt.Sprintf("AND LOWER(u.name) LIKE LOWER($%d) ", len(args)))
 	}
 
 	if param.SortBy == "createdAt" {
-		param.SortBy = "created_at"
+		param.SortBy = "u.created_at"
 	}
 	query.WriteString(fmt.Sprintf("ORDER BY %s %s ", param.SortBy, param.OrderBy))
 
 	query.WriteString(fmt.Sprintf("LIMIT %d OFFSET %d", param.Limit, param.Offset))
 
-	rows, err := u.conn.Query(ctx, query.String())
+	return query.String(), args
+}
+
+func (u *friendRepo) GetFriends(ctx context.Context, param dto.ParamGetFriends, sub string) ([]dto.ResGetFriends, int, error) {
+	q, args := getFriendsQuery(param, sub)
+
+	rows, err := u.conn.Query(ctx, q, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, ierr.WithStack(err)
 	}
 	defer rows.Close()
 
 	results := make([]dto.ResGetFriends, 0, 10)
 	for rows.Next() {
-		var imageUrl sql.NullString
+		var imageUrl, imageBlurHash, imageColor sql.NullString
 		var createdAt time.Time
 
 		result := dto.ResGetFriends{}
-		err := rows.Scan(&result.UserID, &result.Name, &imageUrl, &createdAt, &result.FriendCount)
+		err := rows.Scan(&result.UserID, &result.Name, &imageUrl, &imageBlurHash, &imageColor, &createdAt, &result.FriendCount)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, ierr.WithStack(err)
 		}
 
 		result.ImageURL = imageUrl.String
+		result.ImageBlurHash = imageBlurHash.String
+		result.ImageColor = imageColor.String
 		result.CreatedAt = timepkg.TimeToISO8601(createdAt)
 		results = append(results, result)
 	}
 
-	count, err := u.count(ctx, query.String())
+	count, err := u.count(ctx, q, args)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, ierr.WithStack(err)
 	}
 
 	return results, count, nil
 }
 
-func (u *friendRepo) count(ctx context.Context, q string) (int, error) {
+func (u *friendRepo) count(ctx context.Context, q string, args []any) (int, error) {
 	q = fmt.Sprintf(`SELECT COUNT(*) AS totalRows FROM (%s)`, q)
 	count := 0
-	err := u.conn.QueryRow(ctx, q).Scan(&count)
-	return count, err
+	err := u.conn.QueryRow(ctx, q, args...).Scan(&count)
+	return count, ierr.WithStack(err)
+}
+
+// FilterFriends returns the users out of userIDs that are friends of sub.
+func (u *friendRepo) FilterFriends(ctx context.Context, sub string, userIDs []string) ([]string, error) {
+	q := `SELECT b FROM friends WHERE a = $1 AND b = ANY($2::uuid[])`
+
+	rows, err := u.conn.Query(ctx, q,
+		sub, userIDs)
+	if err != nil {
+		return nil, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	friends := make([]string, 0, len(userIDs))
+	for rows.Next() {
+		friend := ""
+		if err := rows.Scan(&friend); err != nil {
+			return nil, ierr.WithStack(err)
+		}
+		friends = append(friends, friend)
+	}
+
+	return friends, ierr.WithStack(rows.Err())
 }
@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+)
+
+type healthRepo struct {
+	conn *pgxpool.Pool
+}
+
+func newHealthRepo(conn *pgxpool.Pool) *healthRepo {
+	return &healthRepo{conn}
+}
+
+func (u *healthRepo) Ping(ctx context.Context) error {
+	return ierr.WithStack(u.conn.Ping(ctx))
+}
+
+// MigrationVersion reads the schema version golang-migrate recorded, dirty is
+// set when a migration failed halfway. Returns ierr.ErrNotFound when no
+// migration ran yet.
+func (u *healthRepo) MigrationVersion(ctx context.Context) (int, bool, error) {
+	q := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+	version := 0
+	dirty := false
+	err := u.conn.QueryRow(ctx, q).Scan(&version, &dirty)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return 0, false, ierr.ErrNotFound
+		}
+		return 0, false, ierr.WithStack(err)
+	}
+
+	return version, dirty, nil
+}
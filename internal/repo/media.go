@@ -0,0 +1,262 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+)
+
+type mediaRepo struct {
+	conn *pgxpool.Pool
+}
+
+func newMediaRepo(conn *pgxpool.Pool) *mediaRepo {
+	return &mediaRepo{conn}
+}
+
+const mediaColumns = `m.id, m.owner, m.key, m.size, m.hash, m.mime, m.variants, m.blur_hash, m.color, m.created_at`
+
+func scanMedia(row pgx.Row) (entity.Media, error) {
+	m := entity.Media{}
+	var owner sql.NullString
+	err := row.Scan(&m.ID, &owner, &m.Key, &m.Size, &m.Hash, &m.MimeType, &m.Variants, &m.BlurHash, &m.Color, &m.CreatedAt)
+	m.Owner = owner.String
+	return m, ierr.WithStack(err)
+}
+
+// Insert records an upload and charges its size to the owner, failing with
+// ierr.ErrQuotaExceeded when that would go over quota bytes. When shared is
+// set the objects of m.Key already exist and gain a reference, in which case
+// ierr.ErrNotFound means the blob was collected in the meantime.
+func (u *mediaRepo) Insert(ctx context.Context, m entity.Media, quota int64, shared bool) error {
+	tx, err := u.conn.Begin(ctx)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := `UPDATE users SET storage_used = storage_used + $2
+	WHERE id = $1 AND storage_used + $2 <= $3`
+	tag, err := tx.Exec(ctx, q,
+		m.Owner, m.Size, quota)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ierr.ErrQuotaExceeded
+	}
+
+	if shared {
+		q = `UPDATE blobs SET ref_count = ref_count + 1 WHERE key = $1 AND ref_count > 0`
+		tag, err = tx.Exec(ctx, q,
+			m.Key)
+		if err != nil {
+			return ierr.WithStack(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ierr.ErrNotFound
+		}
+	} else {
+		q = `INSERT INTO blobs (key, hash, size, mime, variants, blur_hash, color, ref_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 1)`
+		_, err = tx.Exec(ctx, q,
+			m.Key, m.Hash, m.Size, m.MimeType, m.Variants, m.BlurHash, m.Color)
+		if err != nil {
+			return ierr.WithStack(err)
+		}
+	}
+
+	q = `INSERT INTO media (id, owner, key, size, hash, mime, variants, blur_hash, color)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = tx.Exec(ctx, q,
+		m.ID, m.Owner, m.Key, m.Size, m.Hash, m.MimeType, m.Variants, m.BlurHash, m.Color)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok {
+			if pgErr.Code == "23505" {
+				return ierr.ErrDuplicate
+			}
+		}
+		return ierr.WithStack(err)
+	}
+
+	return ierr.WithStack(tx.Commit(ctx))
+}
+
+func (u *mediaRepo) FindByID(ctx context.Context, id string) (entity.Media, error) {
+	q := `SELECT ` + mediaColumns + ` FROM media m WHERE m.id = $1`
+
+	m, err := scanMedia(u.conn.QueryRow(ctx, q,
+		id))
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return m, ierr.ErrNotFound
+		}
+		return m, ierr.WithStack(err)
+	}
+
+	return m, nil
+}
+
+func (u *mediaRepo) FindByOwnerKey(ctx context.Context, owner, key string) (entity.Media, error) {
+	q := `SELECT ` + mediaColumns + ` FROM media m WHERE m.owner = $1 AND m.key = $2 LIMIT 1`
+
+	m, err := scanMedia(u.conn.QueryRow(ctx, q,
+		owner, key))
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return m, ierr.ErrNotFound
+		}
+		return m, ierr.WithStack(err)
+	}
+
+	return m, nil
+}
+
+func (u *mediaRepo) FindByOwnerHash(ctx context.Context, owner, hash string) (entity.Media, error) {
+	q := `SELECT ` + mediaColumns + ` FROM media m WHERE m.owner = $1 AND m.hash = $2 LIMIT 1`
+
+	m, err := scanMedia(u.conn.QueryRow(ctx, q,
+		owner, hash))
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return m, ierr.ErrNotFound
+		}
+		return m, ierr.WithStack(err)
+	}
+
+	return m, nil
+}
+
+// Reuse restarts the age of unused media handed out again, so the collector
+// keeps it for the full age. Returns ierr.ErrNotFound if it was collected.
+func (u *mediaRepo) Reuse(ctx context.Context, id string) error {
+	q := `UPDATE media SET unreferenced_since = CASE WHEN unreferenced_since IS NULL THEN NULL ELSE CURRENT_TIMESTAMP END
+	WHERE id = $1`
+
+	tag, err := u.conn.Exec(ctx, q,
+		id)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ierr.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindBlobByHash returns a stored blob with the given content as media
+// without id or owner.
+func (u *mediaRepo) FindBlobByHash(ctx context.Context, hash string) (entity.Media, error) {
+	q := `SELECT key, size, hash, mime, variants, blur_hash, color FROM blobs WHERE hash = $1 AND ref_count > 0 LIMIT 1`
+
+	m := entity.Media{}
+	err := u.conn.QueryRow(ctx, q,
+		hash).Scan(&m.Key, &m.Size, &m.Hash, &m.MimeType, &m.Variants, &m.BlurHash, &m.Color)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return m, ierr.ErrNotFound
+		}
+		return m, ierr.WithStack(err)
+	}
+
+	return m, nil
+}
+
+// avatarJoin joins the profile image media of users u as im, for queries
+// reading its placeholder with avatarColumns. Both are NULL without an image.
+const (
+	avatarJoin    = `LEFT JOIN media im ON im.id = u.image_media_id`
+	avatarColumns = `im.blur_hash, im.color`
+)
+
+// mediaUnreferenced holds for media m that nothing points at anymore.
+const mediaUnreferenced = `NOT EXISTS (SELECT 1 FROM users u WHERE u.image_media_id = m.id)
+	AND NOT EXISTS (SELECT 1 FROM post_attachments pa WHERE pa.media_id = m.id)`
+
+// FindUnreferenced lists media no profile or post has used since before
+// olderThan.
+func (u *mediaRepo) FindUnreferenced(ctx context.Context, olderThan time.Time, limit int) ([]entity.Media, error) {
+	q := `SELECT ` + mediaColumns + ` FROM media m
+	WHERE m.unreferenced_since < $1 AND ` + mediaUnreferenced + `
+	ORDER BY m.unreferenced_since ASC LIMIT $2`
+
+	rows, err := u.conn.Query(ctx, q,
+		olderThan, limit)
+	if err != nil {
+		return nil, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]entity.Media, 0, limit)
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, ierr.WithStack(err)
+		}
+		results = append(results, m)
+	}
+
+	return results, ierr.WithStack(rows.Err())
+}
+
+// DeleteUnreferenced removes the media row, gives its size back to the
+// owner's quota and drops a reference to its blob. Returns whether that was
+// the last reference, only then may the objects be deleted. Returns
+// ierr.ErrNotFound if the media got referenced or reused since olderThan.
+func (u *mediaRepo) DeleteUnreferenced(ctx context.Context, id string, olderThan time.Time) (bool, error) {
+	tx, err := u.conn.Begin(ctx)
+	if err != nil {
+		return false, ierr.WithStack(err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := `DELETE FROM media m WHERE m.id = $1 AND m.unreferenced_since < $2 AND ` + mediaUnreferenced + `
+	RETURNING m.owner, m.size, m.key`
+
+	var owner sql.NullString
+	var size int64
+	var key string
+	err = tx.QueryRow(ctx, q,
+		id, olderThan).Scan(&owner, &size, &key)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return false, ierr.ErrNotFound
+		}
+		return false, ierr.WithStack(err)
+	}
+
+	if owner.Valid {
+		q = `UPDATE users SET storage_used = GREATEST(storage_used - $2, 0) WHERE id = $1`
+		_, err = tx.Exec(ctx, q,
+			owner.String, size)
+		if err != nil {
+			return false, ierr.WithStack(err)
+		}
+	}
+
+	refCount := 0
+	q = `UPDATE blobs SET ref_count = ref_count - 1 WHERE key = $1 RETURNING ref_count`
+	err = tx.QueryRow(ctx, q,
+		key).Scan(&refCount)
+	if err != nil {
+		return false, ierr.WithStack(err)
+	}
+
+	if refCount <= 0 {
+		q = `DELETE FROM blobs WHERE key = $1`
+		_, err = tx.Exec(ctx, q,
+			key)
+		if err != nil {
+			return false, ierr.WithStack(err)
+		}
+	}
+
+	return refCount <= 0, ierr.WithStack(tx.Commit(ctx))
+}
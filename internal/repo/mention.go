@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+)
+
+func insertMentions(ctx context.Context, db execer, mentions []entity.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	var values []interface{}
+	for _, m := range mentions {
+		values = append(values, m.PostID, m.CommentID, m.UserID, m.StartAt, m.EndAt)
+	}
+
+	query := "INSERT INTO mentions (post_id, comment_id, user_id, start_at, end_at) VALUES "
+	var placeholders []string
+	for i := 0; i < len(mentions); i++ {
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
+	}
+	query += strings.Join(placeholders, ",")
+
+	_, err := db.Exec(ctx, query, values...)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	return nil
+}
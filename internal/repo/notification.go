@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	timepkg "github.com/vandenbill/social-media-10k-rps/pkg/time"
+)
+
+type notificationRepo struct {
+	conn *pgxpool.Pool
+}
+
+func newNotificationRepo(conn *pgxpool.Pool) *notificationRepo {
+	return &notificationRepo{conn}
+}
+
+func insertNotifications(ctx context.Context, db execer, notifications []entity.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	var values []interface{}
+	for _, n := range notifications {
+		values = append(values, n.UserID, n.ActorID, n.Type, n.PostID, n.CommentID)
+	}
+
+	query := "INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id) VALUES "
+	var placeholders []string
+	for i := 0; i < len(notifications); i++ {
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
+	}
+	query += strings.Join(placeholders, ",")
+
+	_, err := db.Exec(ctx, query, values...)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	return nil
+}
+
+func (r *notificationRepo) GetNotifications(ctx context.Context, param dto.ParamGetNotifications, sub string) ([]dto.ResGetNotification, int, error) {
+	q := `SELECT n.id, n.type, n.post_id, LEFT(COALESCE(p.content_text, ''), 100), n.comment_id, n.created_at,
+		u.id, u.name, u.image_url, ` + avatarColumns + `
+	FROM notifications n JOIN users u ON u.id = n.actor_id ` + avatarJoin + ` JOIN posts p ON p.id = n.post_id
+	WHERE n.user_id = $1
+	ORDER BY n.created_at DESC, n.id DESC LIMIT $2 OFFSET $3`
+
+	rows, err := r.conn.Query(ctx, q,
+		sub, param.Limit, param.Offset)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]dto.ResGetNotification, 0, param.Limit)
+	for rows.Next() {
+		var imageUrl, imageBlurHash, imageColor sql.NullString
+		var createdAt time.Time
+
+		result := dto.ResGetNotification{}
+		err := rows.Scan(&result.NotificationID, &result.Type, &result.PostID, &result.PostText, &result.CommentID, &createdAt,
+			&result.Actor.UserID, &result.Actor.Name, &imageUrl, &imageBlurHash, &imageColor)
+		if err != nil {
+			return nil, 0, ierr.WithStack(err)
+		}
+
+		result.Actor.ImageURL = imageUrl.String
+		result.Actor.ImageBlurHash = imageBlurHash.String
+		result.Actor.ImageColor = imageColor.String
+		result.CreatedAt = timepkg.TimeToISO8601(createdAt)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	q = `SELECT COUNT(*) FROM notifications WHERE user_id = $1`
+
+	count := 0
+	err = r.conn.QueryRow(ctx, q,
+		sub).Scan(&count)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	return results, count, nil
+}
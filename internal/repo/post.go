@@ -2,11 +2,20 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
 	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/pkg/sanitize"
+	timepkg "github.com/vandenbill/social-media-10k-rps/pkg/time"
 )
 
 type postRepo struct {
@@ -17,8 +26,10 @@ func newPostRepo(conn *pgxpool.Pool) *postRepo {
 	return &postRepo{conn}
 }
 
+const isHaveFriendQuery = `SELECT COUNT(*) AS c FROM friends f WHERE f.a = $1`
+
 func (u *postRepo) IsHaveFriend(ctx context.Context, sub string) (bool, error) {
-	q := `SELECT COUNT(*) AS c FROM friends f WHERE f.a = $1`
+	q := isHaveFriendQuery
 
 	c := 0
 	err := u.conn.QueryRow(ctx, q,
@@ -28,47 +39,214 @@ func (u *postRepo) IsHaveFriend(ctx context.Context, sub string) (bool, error) {
 		if err.Error() == "no rows in result set" {
 			return false, ierr.ErrNotFound
 		}
-		return false, err
+		return false, ierr.WithStack(err)
 	}
 
 	return c > 0, nil
 }
 
-func (u *postRepo) AddPost(ctx context.Context, sub, content string) (string, error) {
-	q := `INSERT INTO posts (id, creator, content)
-	VALUES (gen_random_uuid(), $1, $2) RETURNING id`
+// AddPost stores a post together with its tags, attachments, mentions and
+// the notifications for them in one transaction, the PostID of those is set
+// here.
+func (u *postRepo) AddPost(ctx context.Context, post entity.Post, tags []string, attachments []entity.Attachment,
+	mentions []entity.Mention, notifications []entity.Notification) (string, error) {
+	tx, err := u.conn.Begin(ctx)
+	if err != nil {
+		return "", ierr.WithStack(err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := `INSERT INTO posts (id, creator, content, content_text, tags_text)
+	VALUES (gen_random_uuid(), $1, $2, $3, $4) RETURNING id`
 
 	postID := ""
-	err := u.conn.QueryRow(ctx, q,
-		sub, content).Scan(&postID)
+	err = tx.QueryRow(ctx, q,
+		post.Creator, post.Content, post.ContentText, strings.Join(tags, " ")).Scan(&postID)
 
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			if pgErr.Code == "23505" {
-				return postID, ierr.ErrDuplicate
+				return "", ierr.ErrDuplicate
 			}
 		}
-		return postID, err
+		return "", ierr.WithStack(err)
+	}
+
+	for i := range attachments {
+		attachments[i].PostID = postID
+	}
+	for i := range mentions {
+		mentions[i].PostID = postID
+	}
+	for i := range notifications {
+		notifications[i].PostID = postID
 	}
 
-	return postID, nil
+	if err = insertTags(ctx, tx, tags, postID); err != nil {
+		return "", ierr.WithStack(err)
+	}
+	if err = insertAttachments(ctx, tx, attachments); err != nil {
+		return "", ierr.WithStack(err)
+	}
+	if err = insertMentions(ctx, tx, mentions); err != nil {
+		return "", ierr.WithStack(err)
+	}
+	if err = insertNotifications(ctx, tx, notifications); err != nil {
+		return "", ierr.WithStack(err)
+	}
+
+	return postID, ierr.WithStack(tx.Commit(ctx))
 }
 
-func (u *postRepo) AddComment(ctx context.Context, sub, postID, comment string) error {
-	q := `INSERT INTO comments (user_id, post_id, comment)
-	VALUES ($1, $2, $3)`
+func insertAttachments(ctx context.Context, db execer, attachments []entity.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	var values []interface{}
+	for _, a := range attachments {
+		values = append(values, a.PostID, a.MediaID, a.Position, a.AltText)
+	}
 
+	query := "INSERT INTO post_attachments (post_id, media_id, position, alt_text) VALUES "
+	var placeholders []string
+	for i := 0; i < len(attachments); i++ {
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+	}
+	query += strings.Join(placeholders, ",")
+
+	_, err := db.Exec(ctx, query, values...)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	return nil
+}
+
+// DeletePost removes a post with everything hanging off it, its attachments
+// are released for the media garbage collector.
+func (u *postRepo) DeletePost(ctx context.Context, id string) error {
+	q := `DELETE FROM posts WHERE id = $1`
 	_, err := u.conn.Exec(ctx, q,
-		sub, postID, comment)
+		id)
+
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	return nil
+}
+
+// AddComment stores a comment together with its mentions and the
+// notifications for them in one transaction, the CommentID of those is set
+// here.
+func (u *postRepo) AddComment(ctx context.Context, sub, postID, comment string, parentID *int, depth int,
+	mentions []entity.Mention, notifications []entity.Notification) (int, error) {
+	tx, err := u.conn.Begin(ctx)
+	if err != nil {
+		return 0, ierr.WithStack(err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := `INSERT INTO comments (user_id, post_id, comment, parent_comment_id, depth)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	commentID := 0
+	err = tx.QueryRow(ctx, q,
+		sub, postID, comment, parentID, depth).Scan(&commentID)
 
 	if err != nil {
 		if err.Error() == "no rows in result set" {
-			return ierr.ErrNotFound
+			return 0, ierr.ErrNotFound
 		}
-		return err
+		return 0, ierr.WithStack(err)
 	}
 
-	return nil
+	if parentID != nil {
+		q = `UPDATE comments SET reply_count = reply_count + 1 WHERE id = $1`
+		_, err = tx.Exec(ctx, q, *parentID)
+		if err != nil {
+			return 0, ierr.WithStack(err)
+		}
+	}
+
+	for i := range mentions {
+		mentions[i].CommentID = &commentID
+	}
+	for i := range notifications {
+		notifications[i].CommentID = &commentID
+	}
+
+	if err = insertMentions(ctx, tx, mentions); err != nil {
+		return 0, err
+	}
+	if err = insertNotifications(ctx, tx, notifications); err != nil {
+		return 0, err
+	}
+
+	return commentID, ierr.WithStack(tx.Commit(ctx))
+}
+
+func (u *postRepo) FindComment(ctx context.Context, id int) (entity.Comment, error) {
+	q := `SELECT id, post_id, user_id, depth, reply_count FROM comments WHERE id = $1`
+
+	comment := entity.Comment{}
+	err := u.conn.QueryRow(ctx, q,
+		id).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Depth, &comment.ReplyCount)
+
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return comment, ierr.ErrNotFound
+		}
+		return comment, ierr.WithStack(err)
+	}
+
+	return comment, nil
+}
+
+const getRepliesQuery = `SELECT c.id, c.comment, c.parent_comment_id, c.depth, c.reply_count, c.created_at,
+		u.id, u.name, u.image_url, ` + avatarColumns + `,
+		(SELECT COALESCE(jsonb_object_agg(rc.reaction, rc.count) FILTER (WHERE rc.count > 0), '{}')
+			FROM comment_reaction_counts rc WHERE rc.comment_id = c.id),
+		(SELECT COALESCE(jsonb_agg(jsonb_build_object('userId', m.user_id, 'name', mu.name, 'start', m.start_at, 'end', m.end_at) ORDER BY m.start_at), '[]')
+			FROM mentions m JOIN users mu ON mu.id = m.user_id WHERE m.comment_id = c.id)
+	FROM comments c JOIN users u ON u.id = c.user_id ` + avatarJoin + `
+	WHERE c.parent_comment_id = $1
+	ORDER BY c.created_at ASC, c.id ASC LIMIT $2 OFFSET $3`
+
+// GetReplies pages the direct replies of a comment, oldest first.
+func (u *postRepo) GetReplies(ctx context.Context, commentID int, param dto.ParamGetReplies) ([]dto.ResGetComment, error) {
+	q := getRepliesQuery
+
+	rows, err := u.conn.Query(ctx, q,
+		commentID, param.Limit, param.Offset)
+	if err != nil {
+		return nil, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]dto.ResGetComment, 0, param.Limit)
+	for rows.Next() {
+		var imageUrl, imageBlurHash, imageColor sql.NullString
+		var createdAt time.Time
+
+		result := dto.ResGetComment{}
+		err := rows.Scan(&result.CommentID, &result.Comment, &result.ParentCommentID, &result.Depth,
+			&result.ReplyCount, &createdAt,
+			&result.Creator.UserID, &result.Creator.Name, &imageUrl, &imageBlurHash, &imageColor,
+			&result.Reactions, &result.Mentions)
+		if err != nil {
+			return nil, ierr.WithStack(err)
+		}
+
+		result.Creator.ImageURL = imageUrl.String
+		result.Creator.ImageBlurHash = imageBlurHash.String
+		result.Creator.ImageColor = imageColor.String
+		result.CreatedAt = timepkg.TimeToISO8601(createdAt)
+		results = append(results, result)
+	}
+
+	return results, ierr.WithStack(rows.Err())
 }
 
 func (u *postRepo) FindPostCreator(ctx context.Context, id string) (string, error) {
@@ -82,8 +260,206 @@ func (u *postRepo) FindPostCreator(ctx context.Context, id string) (string, erro
 		if err.Error() == "no rows in result set" {
 			return "", ierr.ErrNotFound
 		}
-		return "", err
+		return "", ierr.WithStack(err)
+	}
+
+	return creator, nil
+}
+
+func (u *postRepo) FindCommentPostCreator(ctx context.Context, commentID int) (string, error) {
+	q := `SELECT p.creator FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = $1`
+
+	creator := ""
+	err := u.conn.QueryRow(ctx, q,
+		commentID).Scan(&creator)
+
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return "", ierr.ErrNotFound
+		}
+		return "", ierr.WithStack(err)
 	}
 
 	return creator, nil
 }
+
+// postColumns selects a post as read by scanPost, reaction counts are read
+// from the counter table so no row is counted here. The creator's users u must
+// be joined with avatarJoin.
+const postColumns = `p.id, p.content, p.content_sanitized, p.created_at, u.id, u.name, u.image_url, ` + avatarColumns + `,
+		(SELECT COALESCE(array_agg(t.tag), '{}') FROM tags t WHERE t.post_id = p.id),
+		(SELECT COALESCE(jsonb_object_agg(c.reaction, c.count) FILTER (WHERE c.count > 0), '{}')
+			FROM post_reaction_counts c WHERE c.post_id = p.id),
+		(SELECT COALESCE(jsonb_agg(jsonb_build_object('userId', m.user_id, 'name', mu.name, 'start', m.start_at, 'end', m.end_at) ORDER BY m.start_at), '[]')
+			FROM mentions m JOIN users mu ON mu.id = m.user_id WHERE m.post_id = p.id AND m.comment_id IS NULL),
+		(SELECT COALESCE(jsonb_agg(jsonb_build_object('mediaId', pa.media_id, 'altText', pa.alt_text, 'position', pa.position, 'imageUrls', md.variants, 'blurHash', md.blur_hash, 'color', md.color) ORDER BY pa.position), '[]')
+			FROM post_attachments pa JOIN media md ON md.id = pa.media_id WHERE pa.post_id = p.id)`
+
+// scanPost reads a row selected with postColumns. Attachment imageUrls hold
+// storage keys, the service turns them into URLs. Content the backfill didn't
+// get to yet is sanitized here.
+func scanPost(row pgx.Row, result *dto.ResGetPost, extra ...any) error {
+	var imageUrl, imageBlurHash, imageColor sql.NullString
+	var createdAt time.Time
+	var sanitized bool
+
+	dest := []any{&result.PostID, &result.Post.PostInHTML, &sanitized, &createdAt,
+		&result.Creator.UserID, &result.Creator.Name, &imageUrl, &imageBlurHash, &imageColor,
+		&result.Post.Tags, &result.Reactions, &result.Post.Mentions, &result.Post.Attachments}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	if !sanitized {
+		result.Post.PostInHTML = sanitize.PostHTML(result.Post.PostInHTML)
+	}
+	result.Creator.ImageURL = imageUrl.String
+	result.Creator.ImageBlurHash = imageBlurHash.String
+	result.Creator.ImageColor = imageColor.String
+	result.Post.CreatedAt = timepkg.TimeToISO8601(createdAt)
+	return nil
+}
+
+// feedCreators holds for posts p by sub ($1) or one of their friends. The
+// creators are collected into an array first so posts are looked up through
+// the creator index instead of checking every post against the friends.
+const feedCreators = `p.creator = ANY(ARRAY(SELECT f.b FROM friends f WHERE f.a = $1) || $1::uuid)`
+
+const (
+	getPostsQuery = `SELECT ` + postColumns + `
+	FROM posts p JOIN users u ON u.id = p.creator ` + avatarJoin + `
+	WHERE ` + feedCreators + `
+		AND ($4 = '' OR EXISTS (SELECT 1 FROM tags t WHERE t.post_id = p.id AND t.tag = $4))
+	ORDER BY p.created_at DESC LIMIT $2 OFFSET $3`
+
+	countPostsQuery = `SELECT COUNT(*) FROM posts p
+	WHERE ` + feedCreators + `
+		AND ($2 = '' OR EXISTS (SELECT 1 FROM tags t WHERE t.post_id = p.id AND t.tag = $2))`
+)
+
+// GetPosts returns the feed of the user, their own posts and their friends',
+// optionally only the ones tagged with param.Tag.
+func (u *postRepo) GetPosts(ctx context.Context, param dto.ParamGetPosts, sub string) ([]dto.ResGetPost, int, error) {
+	q := getPostsQuery
+
+	rows, err := u.conn.Query(ctx, q,
+		sub, param.Limit, param.Offset, param.Tag)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]dto.ResGetPost, 0, param.Limit)
+	for rows.Next() {
+		result := dto.ResGetPost{}
+		err := scanPost(rows, &result)
+		if err != nil {
+			return nil, 0, ierr.WithStack(err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	q = countPostsQuery
+
+	count := 0
+	err = u.conn.QueryRow(ctx, q,
+		sub, param.Tag).Scan(&count)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	return results, count, nil
+}
+
+// FindUnsanitizedPosts returns up to limit posts stored before content was
+// sanitized on insert.
+func (u *postRepo) FindUnsanitizedPosts(ctx context.Context, limit int) ([]entity.Post, error) {
+	q := `SELECT id, content FROM posts WHERE NOT content_sanitized LIMIT $1`
+
+	rows, err := u.conn.Query(ctx, q, limit)
+	if err != nil {
+		return nil, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	posts := make([]entity.Post, 0, limit)
+	for rows.Next() {
+		post := entity.Post{}
+		if err := rows.Scan(&post.ID, &post.Content); err != nil {
+			return nil, ierr.WithStack(err)
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, ierr.WithStack(rows.Err())
+}
+
+// SetSanitizedContent replaces the content of a post with its sanitized form.
+func (u *postRepo) SetSanitizedContent(ctx context.Context, post entity.Post) error {
+	q := `UPDATE posts SET content = $2, content_text = $3, content_sanitized = true WHERE id = $1`
+
+	_, err := u.conn.Exec(ctx, q,
+		post.ID, post.Content, post.ContentText)
+	return ierr.WithStack(err)
+}
+
+const (
+	searchPostsQuery = `SELECT ` + postColumns + `,
+		ts_headline('english', translate(COALESCE(p.content_text, ''), $5, ''), query, $6),
+		ts_rank(p.search, query) AS rank
+	FROM posts p JOIN users u ON u.id = p.creator ` + avatarJoin + `,
+		(SELECT websearch_to_tsquery('english', $4) || websearch_to_tsquery('simple', $4) AS query) q
+	WHERE p.search @@ query
+		AND ` + feedCreators + `
+	ORDER BY rank DESC, p.created_at DESC LIMIT $2 OFFSET $3`
+
+	countSearchPostsQuery = `SELECT COUNT(*) FROM posts p,
+		(SELECT websearch_to_tsquery('english', $2) || websearch_to_tsquery('simple', $2) AS query) q
+	WHERE p.search @@ query
+		AND ` + feedCreators
+)
+
+// SearchPosts ranks the posts visible to sub against a web search style query.
+// The query is parsed with both the english and simple configs so stemmed
+// content and verbatim tags both match.
+func (u *postRepo) SearchPosts(ctx context.Context, param dto.ParamSearchPosts, sub string) ([]dto.ResSearchPost, int, error) {
+	q := searchPostsQuery
+
+	rows, err := u.conn.Query(ctx, q,
+		sub, param.Limit, param.Offset, param.Query,
+		sanitize.HeadlineStart+sanitize.HeadlineStop, sanitize.HeadlineOptions)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]dto.ResSearchPost, 0, param.Limit)
+	for rows.Next() {
+		result := dto.ResSearchPost{}
+		err := scanPost(rows, &result.ResGetPost, &result.Headline, &result.Rank)
+		if err != nil {
+			return nil, 0, ierr.WithStack(err)
+		}
+		// content_text is unescaped text, only the marks may become HTML
+		result.Headline = sanitize.Headline(result.Headline)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	q = countSearchPostsQuery
+
+	count := 0
+	err = u.conn.QueryRow(ctx, q,
+		sub, param.Query).Scan(&count)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	return results, count, nil
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	timepkg "github.com/vandenbill/social-media-10k-rps/pkg/time"
+)
+
+type reactionRepo struct {
+	conn *pgxpool.Pool
+}
+
+func newReactionRepo(conn *pgxpool.Pool) *reactionRepo {
+	return &reactionRepo{conn}
+}
+
+type reactionTable struct {
+	reactions string
+	counts    string
+	target    string
+}
+
+var (
+	postReactionTable    = reactionTable{"post_reactions", "post_reaction_counts", "post_id"}
+	commentReactionTable = reactionTable{"comment_reactions", "comment_reaction_counts", "comment_id"}
+)
+
+// TogglePost reacts to a post, or undoes the reaction when the same one is
+// sent twice. Returns the reaction the user has after the toggle, empty if none.
+func (u *reactionRepo) TogglePost(ctx context.Context, sub, postID, reaction string) (string, error) {
+	return u.toggle(ctx, postReactionTable, postID, sub, reaction)
+}
+
+func (u *reactionRepo) ToggleComment(ctx context.Context, sub string, commentID int, reaction string) (string, error) {
+	return u.toggle(ctx, commentReactionTable, commentID, sub, reaction)
+}
+
+func (u *reactionRepo) toggle(ctx context.Context, t reactionTable, targetID any, sub, reaction string) (string, error) {
+	tx, err := u.conn.Begin(ctx)
+	if err != nil {
+		return "", ierr.WithStack(err)
+	}
+	defer tx.Rollback(ctx)
+
+	current := ""
+	for {
+		q := fmt.Sprintf(`SELECT reaction FROM %s WHERE %s = $1 AND user_id = $2 FOR UPDATE`, t.reactions, t.target)
+		err := tx.QueryRow(ctx, q, targetID, sub).Scan(&current)
+		if err != nil && err != pgx.ErrNoRows {
+			return "", ierr.WithStack(err)
+		}
+		if current != "" {
+			break
+		}
+
+		// FOR UPDATE locks nothing while there's no row, ON CONFLICT waits for
+		// a concurrent first reaction and the loop reads that one instead
+		q = fmt.Sprintf(`INSERT INTO %s (%s, user_id, reaction) VALUES ($1, $2, $3)
+		ON CONFLICT (%s, user_id) DO NOTHING`, t.reactions, t.target, t.target)
+		tag, err := tx.Exec(ctx, q, targetID, sub, reaction)
+		if err != nil {
+			return "", ierr.WithStack(err)
+		}
+		if tag.RowsAffected() == 1 {
+			break
+		}
+	}
+
+	result := reaction
+	switch current {
+	case "":
+		// inserted above
+	case reaction:
+		result = ""
+		q := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND user_id = $2`, t.reactions, t.target)
+		_, err = tx.Exec(ctx, q, targetID, sub)
+	default:
+		q := fmt.Sprintf(`UPDATE %s SET reaction = $3, created_at = now() WHERE %s = $1 AND user_id = $2`, t.reactions, t.target)
+		_, err = tx.Exec(ctx, q, targetID, sub, reaction)
+	}
+	if err != nil {
+		return "", ierr.WithStack(err)
+	}
+
+	if current != "" {
+		q := fmt.Sprintf(`UPDATE %s SET count = count - 1 WHERE %s = $1 AND reaction = $2`, t.counts, t.target)
+		if _, err = tx.Exec(ctx, q, targetID, current); err != nil {
+			return "", ierr.WithStack(err)
+		}
+	}
+	if result != "" {
+		q := fmt.Sprintf(`INSERT INTO %s (%s, reaction, count) VALUES ($1, $2, 1)
+		ON CONFLICT (%s, reaction) DO UPDATE SET count = %s.count + 1`, t.counts, t.target, t.target, t.counts)
+		if _, err = tx.Exec(ctx, q, targetID, result); err != nil {
+			return "", ierr.WithStack(err)
+		}
+	}
+
+	return result, ierr.WithStack(tx.Commit(ctx))
+}
+
+func (u *reactionRepo) GetPostReactions(ctx context.Context, postID string, param dto.ParamGetReactions) ([]dto.ResGetReactions, int, error) {
+	q := `SELECT u.id, u.name, u.image_url, ` + avatarColumns + `, r.reaction, r.created_at
+	FROM post_reactions r JOIN users u ON u.id = r.user_id ` + avatarJoin + `
+	WHERE r.post_id = $1 AND ($2 = '' OR r.reaction = $2)
+	ORDER BY r.created_at DESC LIMIT $3 OFFSET $4`
+
+	rows, err := u.conn.Query(ctx, q,
+		postID, param.Reaction, param.Limit, param.Offset)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := make([]dto.ResGetReactions, 0, param.Limit)
+	for rows.Next() {
+		var imageUrl, imageBlurHash, imageColor sql.NullString
+		var createdAt time.Time
+
+		result := dto.ResGetReactions{}
+		err := rows.Scan(&result.UserID, &result.Name, &imageUrl, &imageBlurHash, &imageColor, &result.Reaction, &createdAt)
+		if err != nil {
+			return nil, 0, ierr.WithStack(err)
+		}
+
+		result.ImageURL = imageUrl.String
+		result.ImageBlurHash = imageBlurHash.String
+		result.ImageColor = imageColor.String
+		result.CreatedAt = timepkg.TimeToISO8601(createdAt)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	// total comes from the counters instead of counting the reaction rows
+	q = `SELECT COALESCE(SUM(count), 0) FROM post_reaction_counts
+	WHERE post_id = $1 AND ($2 = '' OR reaction = $2)`
+
+	count := 0
+	err = u.conn.QueryRow(ctx, q,
+		postID, param.Reaction).Scan(&count)
+	if err != nil {
+		return nil, 0, ierr.WithStack(err)
+	}
+
+	return results, count, nil
+}
@@ -1,16 +1,29 @@
 package repo
 
 import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// execer is either the pool or a transaction, so inserts shared by several
+// writes can join the caller's transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
 type Repo struct {
 	conn *pgxpool.Pool
 
-	User   *userRepo
-	Tag    *tagRepo
-	Friend *friendRepo
-	Post   *postRepo
+	User         *userRepo
+	Tag          *tagRepo
+	Friend       *friendRepo
+	Post         *postRepo
+	Reaction     *reactionRepo
+	Notification *notificationRepo
+	Media        *mediaRepo
+	Health       *healthRepo
 }
 
 func NewRepo(conn *pgxpool.Pool) *Repo {
@@ -21,6 +34,10 @@ func NewRepo(conn *pgxpool.Pool) *Repo {
 	repo.Tag = newTagRepo(conn)
 	repo.Friend = newFriendRepo(conn)
 	repo.Post = newPostRepo(conn)
+	repo.Reaction = newReactionRepo(conn)
+	repo.Notification = newNotificationRepo(conn)
+	repo.Media = newMediaRepo(conn)
+	repo.Health = newHealthRepo(conn)
 
 	return &repo
 }
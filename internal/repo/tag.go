@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
 )
 
 type tagRepo struct {
@@ -16,7 +19,7 @@ func newTagRepo(conn *pgxpool.Pool) *tagRepo {
 	return &tagRepo{conn}
 }
 
-func (r *tagRepo) BatchInsert(ctx context.Context, tags []string, postID string) error {
+func insertTags(ctx context.Context, db execer, tags []string, postID string) error {
 	var values []interface{}
 	for _, tag := range tags {
 		values = append(values, postID, tag)
@@ -28,15 +31,41 @@ func (r *tagRepo) BatchInsert(ctx context.Context, tags []string, postID string)
 		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d)", i*2+1, i*2+2))
 	}
 	query += strings.Join(placeholders, ",")
+	query += " ON CONFLICT (post_id, tag) DO NOTHING"
 
-	_, err := r.conn.Exec(ctx, query, values...)
+	_, err := db.Exec(ctx, query, values...)
 	if err != nil {
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
 }
 
+// GetTrending counts tag usage of posts made since the given time.
+func (r *tagRepo) GetTrending(ctx context.Context, since time.Time, limit int) ([]dto.ResTrendingTag, error) {
+	q := `SELECT tag, COUNT(*) AS c FROM tags
+	WHERE created_at > $1
+	GROUP BY tag ORDER BY c DESC, tag ASC LIMIT $2`
+
+	rows, err := r.conn.Query(ctx, q,
+		since, limit)
+	if err != nil {
+		return nil, ierr.WithStack(err)
+	}
+	defer rows.Close()
+
+	tags := make([]dto.ResTrendingTag, 0, limit)
+	for rows.Next() {
+		tag := dto.ResTrendingTag{}
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, ierr.WithStack(err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, ierr.WithStack(rows.Err())
+}
+
 // func (r *tagRepo) DeleteByProductID(ctx context.Context, productID string) error {
 // 	_, err := r.conn.Exec(ctx, `
 // 	DELETE FROM tags WHERE post_id = $1
@@ -39,7 +39,7 @@ func (u *userRepo) Insert(ctx context.Context, user entity.User, isUseEmail bool
 				return "", ierr.ErrDuplicate
 			}
 		}
-		return "", err
+		return "", ierr.WithStack(err)
 	}
 
 	return userID, nil
@@ -56,7 +56,7 @@ func (u *userRepo) LinkEmail(ctx context.Context, email, sub string) error {
 				return ierr.ErrDuplicate
 			}
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
@@ -73,7 +73,7 @@ func (u *userRepo) LinkPhone(ctx context.Context, phone, sub string) error {
 				return ierr.ErrDuplicate
 			}
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
@@ -101,7 +101,7 @@ func (u *userRepo) GetByEmailOrPhone(ctx context.Context, cred string, isUseEmai
 		if err.Error() == "no rows in result set" {
 			return user, ierr.ErrNotFound
 		}
-		return user, err
+		return user, ierr.WithStack(err)
 	}
 
 	return user, nil
@@ -125,7 +125,7 @@ func (u *userRepo) GetByID(ctx context.Context, id string) (entity.User, error)
 		if err.Error() == "no rows in result set" {
 			return user, ierr.ErrNotFound
 		}
-		return user, err
+		return user, ierr.WithStack(err)
 	}
 
 	return user, nil
@@ -142,16 +142,16 @@ func (u *userRepo) LookUp(ctx context.Context, id string) error {
 		if err.Error() == "no rows in result set" {
 			return ierr.ErrNotFound
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
 }
 
-func (u *userRepo) UpdateAccount(ctx context.Context, id, name, url string) error {
-	q := `UPDATE users SET image_url = $1, name = $2 WHERE id = $3`
+func (u *userRepo) UpdateAccount(ctx context.Context, id, name, url, mediaID string) error {
+	q := `UPDATE users SET image_url = $1, name = $2, image_media_id = $3 WHERE id = $4`
 	_, err := u.conn.Exec(ctx, q,
-		url, name, id)
+		url, name, mediaID, id)
 
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
@@ -159,7 +159,7 @@ func (u *userRepo) UpdateAccount(ctx context.Context, id, name, url string) erro
 				return ierr.ErrDuplicate
 			}
 		}
-		return err
+		return ierr.WithStack(err)
 	}
 
 	return nil
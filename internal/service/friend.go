@@ -7,6 +7,7 @@ import (
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/dto"
 	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
 	"github.com/vandenbill/social-media-10k-rps/internal/repo"
 	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
 )
@@ -22,6 +23,9 @@ func newFriendService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.C
 }
 
 func (u *FriendService) AddFriend(ctx context.Context, body dto.ReqAddFriend, sub string) error {
+	ctx, span := startSpan(ctx, "FriendService.AddFriend")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
@@ -43,11 +47,15 @@ func (u *FriendService) AddFriend(ctx context.Context, body dto.ReqAddFriend, su
 		}
 		return err
 	}
+	metrics.Friendships.WithLabelValues("added").Inc()
 
 	return nil
 }
 
 func (u *FriendService) DeleteFriend(ctx context.Context, body dto.ReqDeleteFriend, sub string) error {
+	ctx, span := startSpan(ctx, "FriendService.DeleteFriend")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
@@ -74,11 +82,15 @@ func (u *FriendService) DeleteFriend(ctx context.Context, body dto.ReqDeleteFrie
 	if err != nil {
 		return err
 	}
+	metrics.Friendships.WithLabelValues("removed").Inc()
 
 	return nil
 }
 
 func (u *FriendService) GetFriends(ctx context.Context, param dto.ParamGetFriends, sub string) ([]dto.ResGetFriends, response.Meta, error) {
+	ctx, span := startSpan(ctx, "FriendService.GetFriends")
+	defer span.End()
+
 	meta := response.Meta{}
 
 	if param.Limit == 0 {
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vandenbill/social-media-10k-rps/db"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	"github.com/vandenbill/social-media-10k-rps/pkg/migrate"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
+)
+
+type HealthService struct {
+	repo    *repo.Repo
+	cfg     *cfg.Cfg
+	storage storage.Storage
+
+	// expectedVersion is the latest migration shipped with the server, 0 if
+	// it couldn't be read
+	expectedVersion int
+	started         atomic.Bool
+	draining        atomic.Bool
+}
+
+func newHealthService(repo *repo.Repo, cfg *cfg.Cfg, storage storage.Storage) *HealthService {
+	version, err := migrate.Latest(db.Migrations, "migrations")
+	if err != nil {
+		slog.Error("fail read migrations", logging.Error(err))
+	}
+
+	return &HealthService{repo: repo, cfg: cfg, storage: storage, expectedVersion: version}
+}
+
+// Ready runs every readiness check concurrently. It fails without checking
+// anything once Drain was called, so load balancers stop sending traffic
+// before the server goes away.
+func (u *HealthService) Ready(ctx context.Context) (dto.ResHealth, bool) {
+	if u.draining.Load() {
+		return dto.ResHealth{Status: "draining"}, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.ReadinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"postgres":   u.repo.Health.Ping,
+		"storage":    u.storage.Ping,
+		"migrations": u.checkMigrations,
+	}
+
+	res := dto.ResHealth{Status: "ok", Checks: make(map[string]string, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			res.Checks[name] = result
+			if result != "ok" {
+				res.Status = "unavailable"
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return res, res.Status == "ok"
+}
+
+// Started passes once the server was ready for the first time and from then
+// on, so a startup probe can hold off liveness checks while it boots.
+func (u *HealthService) Started(ctx context.Context) (dto.ResHealth, bool) {
+	if u.started.Load() {
+		return dto.ResHealth{Status: "ok"}, true
+	}
+
+	res, ok := u.Ready(ctx)
+	if ok {
+		u.started.Store(true)
+	}
+	return res, ok
+}
+
+// Drain makes readiness fail from now on, call it first thing on shutdown.
+func (u *HealthService) Drain() {
+	u.draining.Store(true)
+}
+
+func (u *HealthService) checkMigrations(ctx context.Context) error {
+	if u.expectedVersion == 0 {
+		return fmt.Errorf("expected version unknown, no migrations embedded")
+	}
+
+	version, dirty, err := u.repo.Health.MigrationVersion(ctx)
+	if err != nil {
+		if err == ierr.ErrNotFound {
+			return fmt.Errorf("no migration applied, expected version %d", u.expectedVersion)
+		}
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("version %d is dirty", version)
+	}
+	// a newer schema is fine, during a rolling deploy the new replicas
+	// migrate ahead of the old ones still serving
+	if version < u.expectedVersion {
+		return fmt.Errorf("at version %d, expected at least %d", version, u.expectedVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
+	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	"github.com/vandenbill/social-media-10k-rps/pkg/media"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
+	timepkg "github.com/vandenbill/social-media-10k-rps/pkg/time"
+)
+
+type MediaService struct {
+	repo      *repo.Repo
+	validator *validator.Validate
+	cfg       *cfg.Cfg
+	storage   storage.Storage
+}
+
+func newMediaService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg, storage storage.Storage) *MediaService {
+	return &MediaService{repo, validator, cfg, storage}
+}
+
+func (u *MediaService) Rules() media.Rules {
+	return media.Rules{
+		MinBytes:     u.cfg.UploadMinBytes,
+		MaxBytes:     u.cfg.UploadMaxBytes,
+		AllowedTypes: u.cfg.UploadAllowedTypes,
+		MaxWidth:     u.cfg.UploadMaxWidth,
+		MaxHeight:    u.cfg.UploadMaxHeight,
+	}
+}
+
+// Upload checks the uploaded bytes, stores every variant and records them as
+// media owned by sub. Uploads are deduplicated by content hash, per owner and
+// with UploadDedupScope "global" across owners too, in which case the stored
+// objects are shared. The errors of pkg/media are returned as is.
+func (u *MediaService) Upload(ctx context.Context, data []byte, sub string) (dto.ResUpFile, error) {
+	ctx, span := startSpan(ctx, "MediaService.Upload")
+	defer span.End()
+
+	res, err := u.upload(ctx, data, sub)
+	if err != nil {
+		metrics.UploadFailures.WithLabelValues(uploadFailureReason(err)).Inc()
+		return res, err
+	}
+	metrics.UploadBytes.Observe(float64(len(data)))
+
+	return res, nil
+}
+
+func (u *MediaService) upload(ctx context.Context, data []byte, sub string) (dto.ResUpFile, error) {
+	res := dto.ResUpFile{}
+
+	info, err := media.Inspect(data, u.Rules())
+	if err != nil {
+		return res, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	existing, err := u.repo.Media.FindByOwnerHash(ctx, sub, hash)
+	if err == nil {
+		err = u.repo.Media.Reuse(ctx, existing.ID)
+		if err == nil {
+			return u.toResUpFile(existing), nil
+		}
+	}
+	// not found, or collected since we found it, store it like a new upload
+	if err != ierr.ErrNotFound {
+		return res, err
+	}
+
+	if u.cfg.UploadDedupScope == "global" {
+		blob, err := u.repo.Media.FindBlobByHash(ctx, hash)
+		if err != nil && err != ierr.ErrNotFound {
+			return res, err
+		}
+		if err == nil {
+			blob.ID = uuid.NewString()
+			blob.Owner = sub
+			err = u.repo.Media.Insert(ctx, blob, u.cfg.UserStorageQuota, true)
+			if err == nil {
+				return u.toResUpFile(blob), nil
+			}
+			// the blob was collected since we found it, store a fresh copy
+			if err != ierr.ErrNotFound {
+				return res, err
+			}
+		}
+	}
+
+	variants, placeholder, err := media.Process(data, info)
+	if err != nil {
+		return res, err
+	}
+
+	m := entity.Media{
+		ID:       uuid.NewString(),
+		Owner:    sub,
+		Hash:     hash,
+		Variants: make(map[string]string, len(variants)),
+		BlurHash: placeholder.BlurHash,
+		Color:    placeholder.Color,
+	}
+	for _, variant := range variants {
+		key := media.VariantKey(m.ID, variant.Name, variant.Ext)
+
+		err = u.storage.Put(ctx, key, bytes.NewReader(variant.Data), variant.MimeType)
+		if err != nil {
+			u.deleteObjects(m)
+			return res, ierr.WithStack(err)
+		}
+
+		m.Variants[variant.Name] = key
+		m.Size += int64(len(variant.Data))
+		if variant.Name == media.VariantOriginal {
+			m.Key = key
+			m.MimeType = variant.MimeType
+		}
+	}
+
+	err = u.repo.Media.Insert(ctx, m, u.cfg.UserStorageQuota, false)
+	if err != nil {
+		u.deleteObjects(m)
+		return res, err
+	}
+
+	return u.toResUpFile(m), nil
+}
+
+// Presign hands out a URL the client uploads the image to directly, the object
+// lands under a pending key and isn't usable until Complete accepts it.
+func (u *MediaService) Presign(ctx context.Context, body dto.ReqPresign, sub string) (dto.ResPresign, error) {
+	ctx, span := startSpan(ctx, "MediaService.Presign")
+	defer span.End()
+
+	res := dto.ResPresign{}
+
+	presigner, ok := u.storage.(storage.Presigner)
+	if !ok {
+		return res, storage.ErrPresignUnsupported
+	}
+
+	if body.ContentLength < u.cfg.UploadMinBytes {
+		return res, media.ErrTooSmall
+	}
+	if body.ContentLength > u.cfg.UploadMaxBytes {
+		return res, media.ErrTooLarge
+	}
+	if !slices.Contains(u.cfg.UploadAllowedTypes, body.ContentType) {
+		return res, media.ErrUnsupportedType
+	}
+
+	key := pendingKey(sub, uuid.NewString())
+	url, headers, err := presigner.PresignPut(key, body.ContentType, body.ContentLength, u.cfg.PresignExpiry)
+	if err != nil {
+		return res, ierr.WithStack(err)
+	}
+
+	res.Key = key
+	res.UploadURL = url
+	res.Method = "PUT"
+	res.Headers = headers
+	res.ExpiresAt = timepkg.TimeToISO8601(time.Now().Add(u.cfg.PresignExpiry).UTC())
+	return res, nil
+}
+
+// Complete checks a presigned upload against the same rules as Upload and
+// turns it into media. Size and type are checked from the object's metadata
+// before its body is pulled, the body still has to come through here since
+// the variants and the placeholder are made from the decoded image. The
+// pending object is only removed once the media is stored, after a failure
+// the client can complete again and rejected objects are left to the
+// garbage collector.
+func (u *MediaService) Complete(ctx context.Context, body dto.ReqCompleteUpload, sub string) (dto.ResUpFile, error) {
+	ctx, span := startSpan(ctx, "MediaService.Complete")
+	defer span.End()
+
+	res := dto.ResUpFile{}
+
+	presigner, ok := u.storage.(storage.Presigner)
+	if !ok {
+		return res, storage.ErrPresignUnsupported
+	}
+
+	if !strings.HasPrefix(body.Key, pendingKey(sub, "")) {
+		return res, ierr.ErrNotFound
+	}
+
+	info, err := presigner.Stat(ctx, body.Key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return res, ierr.ErrNotFound
+		}
+		return res, ierr.WithStack(err)
+	}
+	if info.Size < u.cfg.UploadMinBytes {
+		return res, media.ErrTooSmall
+	}
+	if info.Size > u.cfg.UploadMaxBytes {
+		return res, media.ErrTooLarge
+	}
+	if !slices.Contains(u.cfg.UploadAllowedTypes, info.ContentType) {
+		return res, media.ErrUnsupportedType
+	}
+
+	object, err := u.storage.Get(ctx, body.Key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return res, ierr.ErrNotFound
+		}
+		return res, ierr.WithStack(err)
+	}
+	data, err := io.ReadAll(io.LimitReader(object, u.cfg.UploadMaxBytes+1))
+	object.Close()
+	if err != nil {
+		return res, ierr.WithStack(err)
+	}
+
+	res, err = u.Upload(ctx, data, sub)
+	if err != nil {
+		return res, err
+	}
+
+	if err := u.storage.Delete(context.WithoutCancel(ctx), body.Key); err != nil {
+		slog.WarnContext(ctx, "fail delete completed upload", slog.String("key", body.Key), logging.Error(err))
+	}
+	return res, nil
+}
+
+// FindOwned resolves the URL of any variant of an upload to its media, which
+// must belong to sub. Shared objects are named after the first uploader so the
+// lookup goes by key rather than by the id in it.
+func (u *MediaService) FindOwned(ctx context.Context, url, sub string) (entity.Media, error) {
+	ctx, span := startSpan(ctx, "MediaService.FindOwned")
+	defer span.End()
+
+	id, _, ok := media.ParseVariantKey(url)
+	if !ok || u.validator.Var(id, "uuid4") != nil {
+		return entity.Media{}, ierr.ErrBadRequest
+	}
+	key := media.VariantKey(id, media.VariantOriginal, path.Ext(url))
+
+	m, err := u.repo.Media.FindByOwnerKey(ctx, sub, key)
+	if err == nil {
+		return m, nil
+	}
+	if err != ierr.ErrNotFound {
+		return m, err
+	}
+
+	_, err = u.repo.Media.FindByID(ctx, id)
+	if err == nil {
+		return m, ierr.ErrForbidden
+	}
+	if err == ierr.ErrNotFound {
+		return m, ierr.ErrBadRequest
+	}
+	return m, err
+}
+
+// ResolveAttachments turns the storage keys read with a post into URLs.
+func (u *MediaService) ResolveAttachments(attachments []dto.ResAttachment) {
+	for _, attachment := range attachments {
+		for name, key := range attachment.ImageURLs {
+			attachment.ImageURLs[name] = u.storage.URL(key)
+		}
+	}
+}
+
+func (u *MediaService) VariantURL(m entity.Media, variant string) string {
+	return u.storage.URL(m.Variants[variant])
+}
+
+func (u *MediaService) toResUpFile(m entity.Media) dto.ResUpFile {
+	res := dto.ResUpFile{MediaID: m.ID, ImageURLs: make(map[string]string, len(m.Variants)), BlurHash: m.BlurHash, Color: m.Color}
+	for name, key := range m.Variants {
+		res.ImageURLs[name] = u.storage.URL(key)
+	}
+	return res
+}
+
+// deleteObjects cleans up after a failed upload, errors are ignored as the
+// objects are unreachable anyway.
+func (u *MediaService) deleteObjects(m entity.Media) {
+	ctx := context.Background()
+	for _, key := range m.Variants {
+		u.storage.Delete(ctx, key)
+	}
+}
+
+// RunGarbageCollector deletes media nothing has referenced for longer than
+// the configured age, every interval until ctx is done. In dry-run mode it only
+// logs and counts what it would delete.
+func (u *MediaService) RunGarbageCollector(ctx context.Context) {
+	ticker := time.NewTicker(u.cfg.MediaGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result := "success"
+		if err := u.collectGarbage(ctx); err != nil {
+			slog.ErrorContext(ctx, "fail collect orphaned media", logging.Error(err))
+			result = "error"
+		}
+		metrics.MediaGCRuns.WithLabelValues(result).Inc()
+	}
+}
+
+func (u *MediaService) collectGarbage(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "MediaService.collectGarbage")
+	defer span.End()
+
+	dryRun := strconv.FormatBool(u.cfg.MediaGCDryRun)
+	olderThan := time.Now().Add(-u.cfg.MediaGCMinAge)
+
+	orphans, err := u.repo.Media.FindUnreferenced(ctx, olderThan, u.cfg.MediaGCBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var deleted int
+	var reclaimed int64
+	for _, m := range orphans {
+		if !u.cfg.MediaGCDryRun {
+			last, err := u.repo.Media.DeleteUnreferenced(ctx, m.ID, olderThan)
+			if err != nil {
+				if err == ierr.ErrNotFound {
+					continue // referenced or reused since it was listed
+				}
+				return err
+			}
+			if !last {
+				continue // objects are still shared with other media
+			}
+			for _, key := range m.Variants {
+				if err := u.storage.Delete(ctx, key); err != nil {
+					slog.WarnContext(ctx, "fail delete orphaned object", slog.String("key", key), logging.Error(err))
+				}
+			}
+		}
+
+		deleted++
+		reclaimed += m.Size
+		metrics.MediaGCDeleted.WithLabelValues(dryRun).Inc()
+		metrics.MediaGCReclaimedBytes.WithLabelValues(dryRun).Add(float64(m.Size))
+	}
+
+	if deleted > 0 {
+		slog.InfoContext(ctx, "orphaned media collected",
+			slog.Int("media", deleted), slog.Int64("bytes", reclaimed), slog.Bool("dry_run", u.cfg.MediaGCDryRun))
+	}
+
+	return u.collectPending(ctx)
+}
+
+// collectPending deletes presigned uploads that were never completed. They
+// get MediaGCMinAge on top of the presign expiry, the client may still be
+// about to complete an upload that finished just in time.
+func (u *MediaService) collectPending(ctx context.Context) error {
+	presigner, ok := u.storage.(storage.Presigner)
+	if !ok {
+		return nil
+	}
+
+	before := time.Now().Add(-u.cfg.PresignExpiry - u.cfg.MediaGCMinAge)
+	keys, err := presigner.ListOlder(ctx, pendingPrefix, before, u.cfg.MediaGCBatchSize)
+	if err != nil {
+		return ierr.WithStack(err)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if !u.cfg.MediaGCDryRun {
+			if err := u.storage.Delete(ctx, key); err != nil {
+				slog.WarnContext(ctx, "fail delete abandoned upload", slog.String("key", key), logging.Error(err))
+				continue
+			}
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		slog.InfoContext(ctx, "abandoned uploads collected",
+			slog.Int("objects", deleted), slog.Bool("dry_run", u.cfg.MediaGCDryRun))
+	}
+
+	return nil
+}
+
+func uploadFailureReason(err error) string {
+	switch err {
+	case media.ErrTooSmall:
+		return "too_small"
+	case media.ErrTooLarge:
+		return "too_large"
+	case media.ErrUnsupportedType:
+		return "unsupported_type"
+	case media.ErrMalformed:
+		return "malformed"
+	case media.ErrDimensions:
+		return "dimensions"
+	case ierr.ErrQuotaExceeded:
+		return "quota_exceeded"
+	}
+	return "internal"
+}
+
+// pendingPrefix starts the keys of presigned uploads not completed yet.
+const pendingPrefix = "pending-"
+
+// pendingKey names a presigned upload, the owner is part of the key so only
+// they can complete it.
+func pendingKey(sub, id string) string {
+	return fmt.Sprintf("%s%s-%s", pendingPrefix, sub, id)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type NotificationService struct {
+	repo      *repo.Repo
+	validator *validator.Validate
+	cfg       *cfg.Cfg
+}
+
+func newNotificationService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg) *NotificationService {
+	return &NotificationService{repo, validator, cfg}
+}
+
+func (u *NotificationService) GetNotifications(ctx context.Context, param dto.ParamGetNotifications, sub string) ([]dto.ResGetNotification, response.Meta, error) {
+	ctx, span := startSpan(ctx, "NotificationService.GetNotifications")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 10
+	}
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	res, count, err := u.repo.Notification.GetNotifications(ctx, param, sub)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	meta.Total = count
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
+
+	return res, meta, nil
+}
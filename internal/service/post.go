@@ -2,30 +2,57 @@ package service
 
 import (
 	"context"
+	"log/slog"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/entity"
 	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
 	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	"github.com/vandenbill/social-media-10k-rps/pkg/mention"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+	"github.com/vandenbill/social-media-10k-rps/pkg/sanitize"
 )
 
 type PostService struct {
 	repo      *repo.Repo
 	validator *validator.Validate
 	cfg       *cfg.Cfg
+	mediaSvc  *MediaService
 }
 
-func newPostService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg) *PostService {
-	return &PostService{repo, validator, cfg}
+func newPostService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg, mediaSvc *MediaService) *PostService {
+	return &PostService{repo, validator, cfg, mediaSvc}
 }
 
 func (u *PostService) AddPost(ctx context.Context, body dto.ReqAddPost, sub string) error {
+	ctx, span := startSpan(ctx, "PostService.AddPost")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
 	}
 
+	body.Tags = normalizeTags(body.Tags)
+	if len(body.Tags) == 0 {
+		return ierr.ErrBadRequest
+	}
+
+	post, err := newPost(sub, body.PostInHTML)
+	if err != nil {
+		return err
+	}
+
+	err = u.checkAttachments(ctx, body.Attachments, sub)
+	if err != nil {
+		return err
+	}
+
 	isHaveFriend, err := u.repo.Post.IsHaveFriend(ctx, sub)
 	if err != nil {
 		return err
@@ -34,19 +61,170 @@ func (u *PostService) AddPost(ctx context.Context, body dto.ReqAddPost, sub stri
 		return ierr.ErrBadRequest
 	}
 
-	postID, err := u.repo.Post.AddPost(ctx, sub, body.PostInHTML)
+	attachments := make([]entity.Attachment, 0, len(body.Attachments))
+	for i, a := range body.Attachments {
+		attachments = append(attachments, entity.Attachment{MediaID: a.MediaID, Position: i, AltText: a.AltText})
+	}
+
+	// the post id is filled in by the repo once the post is inserted
+	mentions, notifications, err := u.visibleMentions(ctx, sub, sub, "", nil, mention.ParseHTML(post.Content))
+	if err != nil {
+		return err
+	}
+
+	_, err = u.repo.Post.AddPost(ctx, post, body.Tags, attachments, mentions, notifications)
 	if err != nil {
 		if err == ierr.ErrDuplicate {
 			return ierr.ErrBadRequest
 		}
 		return err
 	}
+	metrics.PostsCreated.Inc()
+	metrics.TagsPerPost.Observe(float64(len(body.Tags)))
+
+	return nil
+}
+
+// checkAttachments makes sure every attached media was uploaded by sub and
+// is attached only once.
+func (u *PostService) checkAttachments(ctx context.Context, attachments []dto.ReqAttachment, sub string) error {
+	seen := make(map[string]bool, len(attachments))
+	for _, a := range attachments {
+		if seen[a.MediaID] {
+			return ierr.ErrBadRequest
+		}
+		seen[a.MediaID] = true
+
+		m, err := u.repo.Media.FindByID(ctx, a.MediaID)
+		if err != nil {
+			if err == ierr.ErrNotFound {
+				return ierr.ErrBadRequest
+			}
+			return err
+		}
+		if m.Owner != sub {
+			return ierr.ErrForbidden
+		}
+	}
+
+	return nil
+}
+
+func (u *PostService) DeletePost(ctx context.Context, postID, sub string) error {
+	ctx, span := startSpan(ctx, "PostService.DeletePost")
+	defer span.End()
+
+	err := u.validator.Var(postID, "uuid4")
+	if err != nil {
+		return ierr.ErrNotFound
+	}
+
+	creatorID, err := u.repo.Post.FindPostCreator(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if creatorID != sub {
+		return ierr.ErrForbidden
+	}
+
+	return u.repo.Post.DeletePost(ctx, postID)
+}
+
+// sanitizeBatchSize is how many legacy posts RunSanitizeBackfill rewrites at once.
+const sanitizeBatchSize = 100
+
+// RunSanitizeBackfill rewrites posts stored before content was sanitized on
+// insert and returns once none are left or ctx is done. Until then such posts
+// are sanitized on every read.
+func (u *PostService) RunSanitizeBackfill(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := u.sanitizeBatch(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "fail sanitize stored posts", logging.Error(err))
+			return
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+
+	if total > 0 {
+		slog.InfoContext(ctx, "sanitized stored posts", slog.Int("count", total))
+	}
+}
+
+func (u *PostService) sanitizeBatch(ctx context.Context) (int, error) {
+	ctx, span := startSpan(ctx, "PostService.sanitizeBatch")
+	defer span.End()
+
+	posts, err := u.repo.Post.FindUnsanitizedPosts(ctx, sanitizeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, post := range posts {
+		post.Content = sanitize.PostHTML(post.Content)
+		post.ContentText = sanitize.PlainText(post.Content)
+		if err := u.repo.Post.SetSanitizedContent(ctx, post); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(posts), nil
+}
+
+// newPost sanitizes the user supplied HTML, every path that stores post
+// content must go through it.
+func newPost(sub, postInHTML string) (entity.Post, error) {
+	content := sanitize.PostHTML(postInHTML)
+	text := sanitize.PlainText(content)
+	if len(text) < 2 {
+		return entity.Post{}, ierr.ErrBadRequest
+	}
+
+	return entity.Post{Content: content, ContentText: text, Creator: sub}, nil
+}
+
+func (u *PostService) SearchPosts(ctx context.Context, param dto.ParamSearchPosts, sub string) ([]dto.ResSearchPost, response.Meta, error) {
+	ctx, span := startSpan(ctx, "PostService.SearchPosts")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 5
+	}
+	param.Query = strings.TrimSpace(param.Query)
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	res, count, err := u.repo.Post.SearchPosts(ctx, param, sub)
+	if err != nil {
+		return nil, meta, err
+	}
+	for i := range res {
+		u.mediaSvc.ResolveAttachments(res[i].Post.Attachments)
+	}
+
+	meta.Total = count
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
 
-	err = u.repo.Tag.BatchInsert(ctx, body.Tags, postID)
-	return err
+	return res, meta, nil
 }
 
+// maxCommentDepth is how deep replies can nest, top level comments are depth 0.
+const maxCommentDepth = 3
+
 func (u *PostService) AddComment(ctx context.Context, body dto.ReqAddComment, sub string) error {
+	ctx, span := startSpan(ctx, "PostService.AddComment")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
@@ -65,13 +243,170 @@ func (u *PostService) AddComment(ctx context.Context, body dto.ReqAddComment, su
 		return err
 	}
 
-	err = u.repo.Post.AddComment(ctx, sub, body.PostID, body.Comment)
+	depth := 0
+	if body.ParentCommentID != nil {
+		parent, err := u.repo.Post.FindComment(ctx, *body.ParentCommentID)
+		if err != nil {
+			if err == ierr.ErrNotFound {
+				return ierr.ErrBadRequest
+			}
+			return err
+		}
+		if parent.PostID != body.PostID || parent.Depth+1 > maxCommentDepth {
+			return ierr.ErrBadRequest
+		}
+		depth = parent.Depth + 1
+	}
+
+	// the comment id is filled in by the repo once the comment is inserted
+	mentions, notifications, err := u.visibleMentions(ctx, sub, creatorID, body.PostID, nil, mention.Parse(body.Comment))
+	if err != nil {
+		return err
+	}
+
+	_, err = u.repo.Post.AddComment(ctx, sub, body.PostID, body.Comment, body.ParentCommentID, depth, mentions, notifications)
 	if err != nil {
 		if err == ierr.ErrDuplicate {
 			return ierr.ErrBadRequest
 		}
 		return err
 	}
+	metrics.CommentsCreated.Inc()
+
+	return nil
+}
+
+func (u *PostService) GetReplies(ctx context.Context, commentID int, param dto.ParamGetReplies, sub string) ([]dto.ResGetComment, response.Meta, error) {
+	ctx, span := startSpan(ctx, "PostService.GetReplies")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 10
+	}
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	comment, err := u.repo.Post.FindComment(ctx, commentID)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	creatorID, err := u.repo.Post.FindPostCreator(ctx, comment.PostID)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	err = u.repo.Friend.FindFriend(ctx, sub, creatorID)
+	if err != nil {
+		if err == ierr.ErrNotFound {
+			return nil, meta, ierr.ErrBadRequest
+		}
+		return nil, meta, err
+	}
+
+	res, err := u.repo.Post.GetReplies(ctx, commentID, param)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	meta.Total = comment.ReplyCount
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
+
+	return res, meta, nil
+}
+
+func (u *PostService) GetPosts(ctx context.Context, param dto.ParamGetPosts, sub string) ([]dto.ResGetPost, response.Meta, error) {
+	ctx, span := startSpan(ctx, "PostService.GetPosts")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 5
+	}
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	res, count, err := u.repo.Post.GetPosts(ctx, param, sub)
+	if err != nil {
+		return nil, meta, err
+	}
+	for i := range res {
+		u.mediaSvc.ResolveAttachments(res[i].Post.Attachments)
+	}
+
+	meta.Total = count
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
+
+	return res, meta, nil
+}
+
+// visibleMentions turns mentions into the rows to store and the notifications
+// to send. Users that can't see the post, the creator and their friends, are
+// dropped.
+func (u *PostService) visibleMentions(ctx context.Context, sub, creatorID, postID string, commentID *int,
+	mentions []mention.Mention) ([]entity.Mention, []entity.Notification, error) {
+	if len(mentions) == 0 {
+		return nil, nil, nil
+	}
+
+	visible, err := u.repo.Friend.FilterFriends(ctx, creatorID, mention.UserIDs(mentions))
+	if err != nil {
+		return nil, nil, err
+	}
+	canSee := make(map[string]bool, len(visible)+1)
+	canSee[creatorID] = true
+	for _, userID := range visible {
+		canSee[userID] = true
+	}
+
+	entities := make([]entity.Mention, 0, len(mentions))
+	notified := make(map[string]bool, len(mentions))
+	notifications := make([]entity.Notification, 0, len(mentions))
+	for _, m := range mentions {
+		if !canSee[m.UserID] {
+			continue
+		}
+		entities = append(entities, entity.Mention{
+			PostID: postID, CommentID: commentID, UserID: m.UserID, StartAt: m.Start, EndAt: m.End,
+		})
+
+		if m.UserID == sub || notified[m.UserID] {
+			continue
+		}
+		notified[m.UserID] = true
+		notifications = append(notifications, entity.Notification{
+			UserID: m.UserID, ActorID: sub, Type: entity.NotificationTypeMention, PostID: postID, CommentID: commentID,
+		})
+	}
+
+	return entities, notifications, nil
+}
+
+// canSeePost reports whether sub may interact with a post made by creatorID,
+// which is the case for the creator itself and for their friends.
+func canSeePost(ctx context.Context, repo *repo.Repo, sub, creatorID string) error {
+	if sub == creatorID {
+		return nil
+	}
+
+	err := repo.Friend.FindFriend(ctx, sub, creatorID)
+	if err != nil {
+		if err == ierr.ErrNotFound {
+			return ierr.ErrForbidden
+		}
+		return err
+	}
 
 	return nil
 }
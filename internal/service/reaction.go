@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type ReactionService struct {
+	repo      *repo.Repo
+	validator *validator.Validate
+	cfg       *cfg.Cfg
+}
+
+func newReactionService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg) *ReactionService {
+	return &ReactionService{repo, validator, cfg}
+}
+
+func (u *ReactionService) ReactPost(ctx context.Context, body dto.ReqReactPost, sub string) (dto.ResReact, error) {
+	ctx, span := startSpan(ctx, "ReactionService.ReactPost")
+	defer span.End()
+
+	res := dto.ResReact{}
+
+	err := u.validator.Struct(body)
+	if err != nil {
+		return res, ierr.ErrBadRequest
+	}
+
+	creatorID, err := u.repo.Post.FindPostCreator(ctx, body.PostID)
+	if err != nil {
+		return res, err
+	}
+
+	err = canSeePost(ctx, u.repo, sub, creatorID)
+	if err != nil {
+		return res, err
+	}
+
+	reaction, err := u.repo.Reaction.TogglePost(ctx, sub, body.PostID, body.Reaction)
+	if err != nil {
+		return res, err
+	}
+
+	res.Reacted = reaction != ""
+	res.Reaction = reaction
+	return res, nil
+}
+
+func (u *ReactionService) ReactComment(ctx context.Context, body dto.ReqReactComment, sub string) (dto.ResReact, error) {
+	ctx, span := startSpan(ctx, "ReactionService.ReactComment")
+	defer span.End()
+
+	res := dto.ResReact{}
+
+	err := u.validator.Struct(body)
+	if err != nil {
+		return res, ierr.ErrBadRequest
+	}
+
+	creatorID, err := u.repo.Post.FindCommentPostCreator(ctx, body.CommentID)
+	if err != nil {
+		return res, err
+	}
+
+	err = canSeePost(ctx, u.repo, sub, creatorID)
+	if err != nil {
+		return res, err
+	}
+
+	reaction, err := u.repo.Reaction.ToggleComment(ctx, sub, body.CommentID, body.Reaction)
+	if err != nil {
+		return res, err
+	}
+
+	res.Reacted = reaction != ""
+	res.Reaction = reaction
+	return res, nil
+}
+
+func (u *ReactionService) GetPostReactions(ctx context.Context, postID string, param dto.ParamGetReactions, sub string) ([]dto.ResGetReactions, response.Meta, error) {
+	ctx, span := startSpan(ctx, "ReactionService.GetPostReactions")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 10
+	}
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+	err = u.validator.Var(postID, "uuid4")
+	if err != nil {
+		return nil, meta, ierr.ErrNotFound
+	}
+
+	creatorID, err := u.repo.Post.FindPostCreator(ctx, postID)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	err = canSeePost(ctx, u.repo, sub, creatorID)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	res, count, err := u.repo.Reaction.GetPostReactions(ctx, postID, param)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	meta.Total = count
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
+
+	return res, meta, nil
+}
@@ -1,31 +1,55 @@
 package service
 
 import (
+	"context"
+
 	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
 )
 
 type Service struct {
 	repo      *repo.Repo
 	validator *validator.Validate
 	cfg       *cfg.Cfg
+	storage   storage.Storage
 
-	User   *UserService
-	Friend *FriendService
-	Post   *PostService
+	User         *UserService
+	Friend       *FriendService
+	Post         *PostService
+	Reaction     *ReactionService
+	Notification *NotificationService
+	Tag          *TagService
+	Media        *MediaService
+	Health       *HealthService
 }
 
-func NewService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg) *Service {
+func NewService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg, storage storage.Storage) *Service {
 	service := Service{}
 	service.repo = repo
 	service.validator = validator
 	service.cfg = cfg
+	service.storage = storage
 
-	service.User = newUserService(repo, validator, cfg)
+	service.Media = newMediaService(repo, validator, cfg, storage)
+	service.User = newUserService(repo, validator, cfg, service.Media)
 	service.Friend = newFriendService(repo, validator, cfg)
-	service.Post = newPostService(repo, validator, cfg)
+	service.Post = newPostService(repo, validator, cfg, service.Media)
+	service.Reaction = newReactionService(repo, validator, cfg)
+	service.Notification = newNotificationService(repo, validator, cfg)
+	service.Tag = newTagService(repo, validator, cfg, service.Media)
+	service.Health = newHealthService(repo, cfg, storage)
 
 	return &service
 }
+
+var tracer = otel.Tracer("github.com/vandenbill/social-media-10k-rps/internal/service")
+
+// startSpan starts the span of a service method, name is Type.Method.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name)
+}
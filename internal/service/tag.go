@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
+	"github.com/vandenbill/social-media-10k-rps/internal/dto"
+	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/repo"
+	response "github.com/vandenbill/social-media-10k-rps/pkg/resp"
+)
+
+type TagService struct {
+	repo      *repo.Repo
+	validator *validator.Validate
+	cfg       *cfg.Cfg
+	mediaSvc  *MediaService
+
+	mu       sync.RWMutex
+	trending []dto.ResTrendingTag
+}
+
+func newTagService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg, mediaSvc *MediaService) *TagService {
+	return &TagService{repo: repo, validator: validator, cfg: cfg, mediaSvc: mediaSvc, trending: []dto.ResTrendingTag{}}
+}
+
+func (u *TagService) GetTagPosts(ctx context.Context, tag string, param dto.ParamGetPosts, sub string) ([]dto.ResGetPost, response.Meta, error) {
+	ctx, span := startSpan(ctx, "TagService.GetTagPosts")
+	defer span.End()
+
+	meta := response.Meta{}
+
+	if param.Limit == 0 {
+		param.Limit = 5
+	}
+
+	param.Tag = normalizeTag(tag)
+	if param.Tag == "" {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	err := u.validator.Struct(param)
+	if err != nil {
+		return nil, meta, ierr.ErrBadRequest
+	}
+
+	res, count, err := u.repo.Post.GetPosts(ctx, param, sub)
+	if err != nil {
+		return nil, meta, err
+	}
+	for i := range res {
+		u.mediaSvc.ResolveAttachments(res[i].Post.Attachments)
+	}
+
+	meta.Total = count
+	meta.Limit = param.Limit
+	meta.Offset = param.Offset
+
+	return res, meta, nil
+}
+
+// GetTrending returns the last computed trending tags, see RunTrendingRefresher.
+func (u *TagService) GetTrending() []dto.ResTrendingTag {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.trending
+}
+
+// RunTrendingRefresher recomputes the trending tags over the configured window
+// every interval until ctx is done, so requests only ever read the cached list.
+func (u *TagService) RunTrendingRefresher(ctx context.Context) {
+	ticker := time.NewTicker(u.cfg.TrendingTagsInterval)
+	defer ticker.Stop()
+
+	for {
+		u.refreshTrending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (u *TagService) refreshTrending(ctx context.Context) {
+	ctx, span := startSpan(ctx, "TagService.refreshTrending")
+	defer span.End()
+
+	since := time.Now().Add(-u.cfg.TrendingTagsWindow)
+	trending, err := u.repo.Tag.GetTrending(ctx, since, u.cfg.TrendingTagsLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "fail refresh trending tags", logging.Error(err))
+		return
+	}
+
+	u.mu.Lock()
+	u.trending = trending
+	u.mu.Unlock()
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags lowercases and trims the tags, dropping empty and repeated ones.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/dto"
 	"github.com/vandenbill/social-media-10k-rps/internal/ierr"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
 	"github.com/vandenbill/social-media-10k-rps/internal/repo"
 	"github.com/vandenbill/social-media-10k-rps/pkg/auth"
+	"github.com/vandenbill/social-media-10k-rps/pkg/media"
 	validatorPkg "github.com/vandenbill/social-media-10k-rps/pkg/validator"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -18,13 +20,17 @@ type UserService struct {
 	repo      *repo.Repo
 	validator *validator.Validate
 	cfg       *cfg.Cfg
+	mediaSvc  *MediaService
 }
 
-func newUserService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg) *UserService {
-	return &UserService{repo, validator, cfg}
+func newUserService(repo *repo.Repo, validator *validator.Validate, cfg *cfg.Cfg, mediaSvc *MediaService) *UserService {
+	return &UserService{repo, validator, cfg, mediaSvc}
 }
 
 func (u *UserService) Register(ctx context.Context, body dto.ReqRegister) (dto.ResRegister, error) {
+	ctx, span := startSpan(ctx, "UserService.Register")
+	defer span.End()
+
 	res := dto.ResRegister{}
 
 	err := u.validator.Struct(body)
@@ -48,13 +54,16 @@ func (u *UserService) Register(ctx context.Context, body dto.ReqRegister) (dto.R
 		}
 	}
 
+	_, hashSpan := startSpan(ctx, "bcrypt.GenerateFromPassword")
 	isUseEmail, user := body.ToEntity(u.cfg.BCryptSalt)
+	hashSpan.End()
 	userID, err := u.repo.User.Insert(ctx, user, isUseEmail)
 	if err != nil {
 		return res, err
 	}
+	metrics.Registrations.WithLabelValues(string(body.CredentialType)).Inc()
 
-	token, _, err := auth.GenerateToken(u.cfg.JWTSecret, 8, auth.JwtPayload{Sub: userID})
+	token, _, err := auth.GenerateToken(u.cfg.JWTSecret, u.cfg.JWTExpiry, auth.JwtPayload{Sub: userID})
 	if err != nil {
 		return res, err
 	}
@@ -71,6 +80,9 @@ func (u *UserService) Register(ctx context.Context, body dto.ReqRegister) (dto.R
 }
 
 func (u *UserService) Login(ctx context.Context, body dto.ReqLogin) (dto.ResLogin, error) {
+	ctx, span := startSpan(ctx, "UserService.Login")
+	defer span.End()
+
 	res := dto.ResLogin{}
 
 	err := u.validator.Struct(body)
@@ -98,21 +110,30 @@ func (u *UserService) Login(ctx context.Context, body dto.ReqLogin) (dto.ResLogi
 
 	user, err := u.repo.User.GetByEmailOrPhone(ctx, body.CredentialValue, isUseEmail)
 	if err != nil {
+		if err == ierr.ErrNotFound {
+			metrics.Logins.WithLabelValues("failure").Inc()
+		}
 		return res, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+	_, hashSpan := startSpan(ctx, "bcrypt.CompareHashAndPassword")
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password))
+	hashSpan.End()
+	if err != nil {
 		if err == bcrypt.ErrMismatchedHashAndPassword {
+			metrics.Logins.WithLabelValues("failure").Inc()
 			return res, ierr.ErrBadRequest
 		}
 		return res, err
 	}
 
-	token, _, err := auth.GenerateToken(u.cfg.JWTSecret, 8, auth.JwtPayload{Sub: user.ID})
+	token, _, err := auth.GenerateToken(u.cfg.JWTSecret, u.cfg.JWTExpiry, auth.JwtPayload{Sub: user.ID})
 	if err != nil {
 		return res, err
 	}
 
+	metrics.Logins.WithLabelValues("success").Inc()
+
 	res.Email = user.Email
 	res.Phone = user.PhoneNumber
 	res.Name = user.Name
@@ -122,6 +143,9 @@ func (u *UserService) Login(ctx context.Context, body dto.ReqLogin) (dto.ResLogi
 }
 
 func (u *UserService) LinkEmail(ctx context.Context, body dto.ReqLinkEmail, sub string) error {
+	ctx, span := startSpan(ctx, "UserService.LinkEmail")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
@@ -140,6 +164,9 @@ func (u *UserService) LinkEmail(ctx context.Context, body dto.ReqLinkEmail, sub
 }
 
 func (u *UserService) LinkPhone(ctx context.Context, body dto.ReqLinkPhone, sub string) error {
+	ctx, span := startSpan(ctx, "UserService.LinkPhone")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
@@ -158,20 +185,27 @@ func (u *UserService) LinkPhone(ctx context.Context, body dto.ReqLinkPhone, sub
 }
 
 func (u *UserService) UpdateAccount(ctx context.Context, body dto.ReqUpdateAccount, sub string) error {
+	ctx, span := startSpan(ctx, "UserService.UpdateAccount")
+	defer span.End()
+
 	err := u.validator.Struct(body)
 	if err != nil {
 		return ierr.ErrBadRequest
 	}
 
-	if body.ImageURL == "http://incomplete"{
-		return ierr.ErrBadRequest
+	err = u.repo.User.LookUp(ctx, sub)
+	if err != nil {
+		return err
 	}
 
-	err = u.repo.User.LookUp(ctx, sub)
+	image, err := u.mediaSvc.FindOwned(ctx, body.ImageURL, sub)
 	if err != nil {
 		return err
 	}
 
-	err = u.repo.User.UpdateAccount(ctx, sub, body.Name, body.ImageURL)
+	// profiles always show the avatar sized variant whichever one was sent
+	imageURL := u.mediaSvc.VariantURL(image, media.VariantAvatar)
+
+	err = u.repo.User.UpdateAccount(ctx, sub, body.Name, imageURL, image.ID)
 	return err
 }
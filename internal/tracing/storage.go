@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
+)
+
+var storageTracer = otel.Tracer("github.com/vandenbill/social-media-10k-rps/internal/tracing/storage")
+
+type tracedStorage struct {
+	storage.Storage
+	backend string
+}
+
+func (s *tracedStorage) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "ping", "")
+	err := s.Storage.Ping(ctx)
+	end(span, err)
+	return err
+}
+
+// tracedPresigner keeps the wrapped backend a storage.Presigner, presigning
+// is only local signing so only listing and stat are traced.
+type tracedPresigner struct {
+	tracedStorage
+	storage.Presigner
+}
+
+func (s *tracedPresigner) ListOlder(ctx context.Context, prefix string, before time.Time, limit int) ([]string, error) {
+	ctx, span := s.start(ctx, "list", prefix)
+	keys, err := s.Presigner.ListOlder(ctx, prefix, before, limit)
+	end(span, err)
+	return keys, err
+}
+
+func (s *tracedPresigner) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	ctx, span := s.start(ctx, "stat", key)
+	info, err := s.Presigner.Stat(ctx, key)
+	end(span, err)
+	return info, err
+}
+
+// Storage wraps s so every call to the backend gets a span, backend names it
+// in the span attributes.
+func Storage(s storage.Storage, backend string) storage.Storage {
+	traced := tracedStorage{s, backend}
+	if presigner, ok := s.(storage.Presigner); ok {
+		return &tracedPresigner{traced, presigner}
+	}
+	return &traced
+}
+
+func (s *tracedStorage) start(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	return storageTracer.Start(ctx, "storage "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.backend", s.backend),
+			attribute.String("storage.key", key),
+		))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && err != storage.ErrNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *tracedStorage) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	ctx, span := s.start(ctx, "put", key)
+	err := s.Storage.Put(ctx, key, body, contentType)
+	end(span, err)
+	return err
+}
+
+// Get covers opening the object, reading the body happens after the span ends.
+func (s *tracedStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, span := s.start(ctx, "get", key)
+	body, err := s.Storage.Get(ctx, key)
+	end(span, err)
+	return body, err
+}
+
+func (s *tracedStorage) Delete(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "delete", key)
+	err := s.Storage.Delete(ctx, key)
+	end(span, err)
+	return err
+}
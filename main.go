@@ -1,36 +1,213 @@
 package main
 
+//go:generate go run ./monitoring/gen
+
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vandenbill/social-media-10k-rps/db"
 	"github.com/vandenbill/social-media-10k-rps/internal/cfg"
 	"github.com/vandenbill/social-media-10k-rps/internal/handler"
+	"github.com/vandenbill/social-media-10k-rps/internal/logging"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
 	"github.com/vandenbill/social-media-10k-rps/internal/repo"
 	"github.com/vandenbill/social-media-10k-rps/internal/service"
+	"github.com/vandenbill/social-media-10k-rps/internal/tracing"
 	"github.com/vandenbill/social-media-10k-rps/pkg/env"
+	"github.com/vandenbill/social-media-10k-rps/pkg/migrate"
 	"github.com/vandenbill/social-media-10k-rps/pkg/postgre"
 	"github.com/vandenbill/social-media-10k-rps/pkg/router"
+	"github.com/vandenbill/social-media-10k-rps/pkg/storage"
 	"github.com/vandenbill/social-media-10k-rps/pkg/validator"
 )
 
 func main() {
 	env.LoadEnv()
 
-	ctx := context.Background()
+	cfg, err := cfg.Load()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], cfg, err))
+	}
+	if err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+
+	err = logging.Setup(cfg.LogLevel)
+	if err != nil {
+		log.Fatalln("fail setup logging:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg)
+	if err != nil {
+		fatal("fail setup tracing", err)
+	}
+
 	router := router.NewRouter()
-	conn := postgre.GetConn(ctx)
-	defer conn.Close()
+	conn := postgre.GetConn(ctx, postgreConfig(cfg), tracing.NewQueryTracer())
 	validator := validator.New()
 
-	cfg := cfg.Load()
+	if cfg.AutoMigrate {
+		autoMigrate(ctx, conn)
+	}
+
+	metrics.RegisterPool(conn)
+	metricsServer := metrics.NewServer(cfg.PrometheusAddr)
+
+	storage := tracing.Storage(newStorage(cfg), cfg.StorageBackend)
 	repo := repo.NewRepo(conn)
-	service := service.NewService(repo, validator, cfg)
-	handler.NewHandler(router, service, cfg)
+	service := service.NewService(repo, validator, cfg, storage)
+	handler.NewHandler(router, service, storage, cfg)
 
-	log.Println("server started on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalln("fail start server:", err)
+	// background workers get their own context, they are stopped only once
+	// the server stopped taking requests
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range []func(context.Context){service.Tag.RunTrendingRefresher, service.Media.RunGarbageCollector, service.Post.RunSanitizeBackfill} {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(workerCtx)
+		}(run)
 	}
+
+	server := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           router,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	serveErr := make(chan error, 2)
+	for _, srv := range []*http.Server{server, metricsServer} {
+		go func(srv *http.Server) {
+			slog.Info("server started", slog.String("addr", srv.Addr))
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serveErr <- err
+			}
+		}(srv)
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		fatal("fail start server", err)
+	}
+	// a second signal kills the process right away
+	stop()
+
+	// fail readiness first so load balancers stop routing to us while we
+	// still serve everything in flight
+	slog.Info("shutting down, draining", slog.Duration("delay", cfg.ShutdownDrainDelay))
+	service.Health.Drain()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("fail drain requests, closing connections", logging.Error(err))
+		server.Close()
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		slog.Error("background workers did not stop in time")
+	}
+
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		metricsServer.Close()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("fail flush traces", logging.Error(err))
+	}
+
+	// last, everything above may still be using it
+	conn.Close()
+	slog.Info("shutdown complete")
+}
+
+func postgreConfig(cfg *cfg.Cfg) postgre.Config {
+	return postgre.Config{
+		Name:            cfg.DBName,
+		Port:            cfg.DBPort,
+		Host:            cfg.DBHost,
+		Username:        cfg.DBUsername,
+		Password:        cfg.DBPassword,
+		SSLMode:         cfg.DBSSLMode,
+		SSLRootCert:     cfg.DBSSLRootCert,
+		MaxConns:        cfg.DBMaxConns,
+		MaxConnIdleTime: cfg.DBMaxConnIdleTime,
+		MaxConnLifetime: cfg.DBMaxConnLifetime,
+		ConnectTimeout:  cfg.DBConnectTimeout,
+	}
+}
+
+// autoMigrate brings the schema up to date before serving, it waits for any
+// other replica migrating at the same time.
+func autoMigrate(ctx context.Context, conn *pgxpool.Pool) {
+	migrator, err := migrate.New(conn, db.Migrations, "migrations")
+	if err != nil {
+		fatal("fail read migrations", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	for _, mig := range applied {
+		slog.Info("migration applied", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+	}
+	if err != nil {
+		fatal("fail migrate", err)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Error(err))
+	os.Exit(1)
+}
+
+func newStorage(cfg *cfg.Cfg) storage.Storage {
+	var s storage.Storage
+	var err error
+
+	switch cfg.StorageBackend {
+	case "local":
+		s, err = storage.NewLocal(cfg.LocalStorageDir, cfg.LocalStorageBaseURL)
+	case "s3":
+		s, err = storage.NewS3(storage.S3Config{
+			ID:         cfg.S3ID,
+			SecretKey:  cfg.S3SecretKey,
+			BucketName: cfg.S3BucketName,
+			Region:     cfg.S3Region,
+			Endpoint:   cfg.S3Endpoint,
+			PublicURL:  cfg.S3BaseURL,
+		})
+	default:
+		err = fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+	if err != nil {
+		fatal("fail create storage", err)
+	}
+
+	return s
 }
@@ -0,0 +1,389 @@
+// Command gen writes the Grafana dashboard and the Prometheus alert rules in
+// monitoring/ from the metrics the server registers. Every metric a query uses
+// is checked against them, so renaming or dropping a metric fails generation
+// instead of leaving a dashboard that silently shows nothing.
+//
+// Run it from the repository root with go generate.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vandenbill/social-media-10k-rps/internal/metrics"
+)
+
+var known = map[string]bool{}
+
+func main() {
+	out := flag.String("out", "monitoring", "directory to write the generated files to")
+	flag.Parse()
+
+	loadKnown()
+
+	dashboard, err := json.MarshalIndent(newDashboard(), "", "  ")
+	if err != nil {
+		log.Fatalln("fail encode dashboard:", err)
+	}
+	write(filepath.Join(*out, "grafana", "dashboards", "social-media.json"), append(dashboard, '\n'))
+
+	var rules strings.Builder
+	err = rulesTemplate.Execute(&rules, alertGroups())
+	if err != nil {
+		log.Fatalln("fail render alert rules:", err)
+	}
+	write(filepath.Join(*out, "prometheus", "alerts.yml"), []byte(rules.String()))
+}
+
+func write(path string, data []byte) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		log.Fatalln("fail write", path, err)
+	}
+	log.Println("wrote", path)
+}
+
+var fqName = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// loadKnown collects the name of every metric the server exposes. Vectors
+// without children don't show up in a Gather so they are described instead.
+func loadKnown() {
+	collectors := []prometheus.Collector{
+		metrics.HTTPRequests, metrics.HTTPRequestDuration, metrics.HTTPResponseSize, metrics.HTTPRequestsInFlight,
+		metrics.NewPoolCollector(nil),
+		metrics.Registrations, metrics.Logins, metrics.Friendships, metrics.PostsCreated, metrics.CommentsCreated,
+		metrics.TagsPerPost, metrics.UploadBytes, metrics.UploadFailures,
+		metrics.MediaGCRuns, metrics.MediaGCDeleted, metrics.MediaGCReclaimedBytes,
+	}
+	for _, c := range collectors {
+		ch := make(chan *prometheus.Desc, 16)
+		c.Describe(ch)
+		close(ch)
+		for desc := range ch {
+			if m := fqName.FindStringSubmatch(desc.String()); m != nil {
+				known[m[1]] = true
+			}
+		}
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		log.Fatalln("fail gather metrics:", err)
+	}
+	for _, family := range families {
+		known[family.GetName()] = true
+	}
+}
+
+// m returns name after making sure the server exposes it, histogram series
+// are checked by the name of the histogram.
+func m(name string) string {
+	base := name
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name && known[trimmed] {
+			base = trimmed
+		}
+	}
+	if !known[base] {
+		log.Fatalf("metric %s is not exposed by the server", name)
+	}
+	return name
+}
+
+const datasourceUID = "prometheus"
+
+type (
+	datasource struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+	gridPos struct {
+		H int `json:"h"`
+		W int `json:"w"`
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	target struct {
+		Datasource   datasource `json:"datasource"`
+		Expr         string     `json:"expr"`
+		LegendFormat string     `json:"legendFormat"`
+		RefID        string     `json:"refId"`
+	}
+	fieldConfig struct {
+		Defaults  map[string]any `json:"defaults"`
+		Overrides []any          `json:"overrides"`
+	}
+	panel struct {
+		ID          int          `json:"id"`
+		Type        string       `json:"type"`
+		Title       string       `json:"title"`
+		Description string       `json:"description,omitempty"`
+		Datasource  *datasource  `json:"datasource,omitempty"`
+		GridPos     gridPos      `json:"gridPos"`
+		FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+		Targets     []target     `json:"targets,omitempty"`
+		Collapsed   *bool        `json:"collapsed,omitempty"`
+		Panels      []panel      `json:"panels,omitempty"`
+	}
+	dashboard struct {
+		UID           string         `json:"uid"`
+		Title         string         `json:"title"`
+		Tags          []string       `json:"tags"`
+		Timezone      string         `json:"timezone"`
+		SchemaVersion int            `json:"schemaVersion"`
+		Version       int            `json:"version"`
+		Editable      bool           `json:"editable"`
+		Refresh       string         `json:"refresh"`
+		Time          map[string]any `json:"time"`
+		Templating    map[string]any `json:"templating"`
+		Panels        []panel        `json:"panels"`
+	}
+)
+
+// query is a panel query, legend uses the Grafana {{label}} syntax.
+type query struct {
+	expr   string
+	legend string
+}
+
+// layout places panels left to right, two per row, below a row header.
+type layout struct {
+	panels []panel
+	y, x   int
+}
+
+func (l *layout) row(title string) {
+	if l.x > 0 {
+		l.y += 8
+		l.x = 0
+	}
+	collapsed := false
+	l.panels = append(l.panels, panel{
+		ID: len(l.panels) + 1, Type: "row", Title: title, Collapsed: &collapsed,
+		GridPos: gridPos{H: 1, W: 24, X: 0, Y: l.y},
+	})
+	l.y++
+}
+
+func (l *layout) timeseries(title, unit, description string, queries ...query) {
+	ds := datasource{Type: "prometheus", UID: datasourceUID}
+	p := panel{
+		ID: len(l.panels) + 1, Type: "timeseries", Title: title, Description: description,
+		Datasource:  &ds,
+		GridPos:     gridPos{H: 8, W: 12, X: l.x, Y: l.y},
+		FieldConfig: &fieldConfig{Defaults: map[string]any{"unit": unit}, Overrides: []any{}},
+	}
+	for i, q := range queries {
+		p.Targets = append(p.Targets, target{Datasource: ds, Expr: q.expr, LegendFormat: q.legend, RefID: string(rune('A' + i))})
+	}
+	l.panels = append(l.panels, p)
+
+	l.x += 12
+	if l.x == 24 {
+		l.x = 0
+		l.y += 8
+	}
+}
+
+func quantile(q float64, histogram, by string) string {
+	return fmt.Sprintf(`histogram_quantile(%g, sum by (le%s) (rate(%s[5m])))`, q, by, m(histogram+"_bucket"))
+}
+
+func newDashboard() dashboard {
+	l := &layout{}
+
+	l.row("HTTP")
+	l.timeseries("Requests by route", "reqps", "",
+		query{fmt.Sprintf(`sum by (method, route) (rate(%s[5m]))`, m("http_requests_total")), "{{method}} {{route}}"})
+	l.timeseries("Server error ratio", "percentunit", "Share of responses with a 5xx status.",
+		query{fmt.Sprintf(`sum by (route) (rate(%[1]s{status=~"5.."}[5m])) / sum by (route) (rate(%[1]s[5m]))`, m("http_requests_total")), "{{route}}"})
+	l.timeseries("Latency p50 / p95 / p99", "s", "",
+		query{quantile(0.5, "http_request_duration_seconds", ""), "p50"},
+		query{quantile(0.95, "http_request_duration_seconds", ""), "p95"},
+		query{quantile(0.99, "http_request_duration_seconds", ""), "p99"})
+	l.timeseries("Latency p95 by route", "s", "",
+		query{quantile(0.95, "http_request_duration_seconds", ", route"), "{{route}}"})
+	l.timeseries("In-flight requests", "short", "",
+		query{m("http_requests_in_flight"), "in flight"})
+	l.timeseries("Response size p95 by route", "bytes", "",
+		query{quantile(0.95, "http_response_size_bytes", ", route"), "{{route}}"})
+
+	l.row("Database pool")
+	l.timeseries("Connections", "short", "",
+		query{m("pgxpool_acquired_conns"), "acquired"},
+		query{m("pgxpool_idle_conns"), "idle"},
+		query{m("pgxpool_total_conns"), "total"},
+		query{m("pgxpool_max_conns"), "max"})
+	l.timeseries("Acquire wait", "s", "Average time to acquire a connection, and how often an acquire had to wait.",
+		query{fmt.Sprintf(`rate(%s[5m]) / rate(%s[5m])`, m("pgxpool_acquire_duration_seconds_total"), m("pgxpool_acquires_total")), "avg wait"},
+		query{fmt.Sprintf(`rate(%s[5m])`, m("pgxpool_empty_acquires_total")), "waiting acquires/s"})
+
+	l.row("Go runtime")
+	l.timeseries("Goroutines", "short", "",
+		query{m("go_goroutines"), "goroutines"})
+	l.timeseries("Heap in use", "bytes", "",
+		query{m("go_memstats_heap_inuse_bytes"), "heap"},
+		query{m("process_resident_memory_bytes"), "rss"})
+	l.timeseries("GC pause", "s", "",
+		query{fmt.Sprintf(`rate(%s[5m]) / rate(%s[5m])`, m("go_gc_duration_seconds_sum"), m("go_gc_duration_seconds_count")), "avg pause"})
+	l.timeseries("Scheduler latency p99", "s", "Time goroutines spend runnable before they run.",
+		query{quantile(0.99, "go_sched_latencies_seconds", ""), "p99"})
+
+	l.row("Social")
+	l.timeseries("Registrations and logins", "short", "",
+		query{fmt.Sprintf(`sum by (credential_type) (increase(%s[1h]))`, m("user_registrations_total")), "registered {{credential_type}}"},
+		query{fmt.Sprintf(`sum by (result) (increase(%s[1h]))`, m("user_logins_total")), "login {{result}}"})
+	l.timeseries("Posts, comments and friendships", "short", "",
+		query{fmt.Sprintf(`increase(%s[1h])`, m("posts_created_total")), "posts"},
+		query{fmt.Sprintf(`increase(%s[1h])`, m("comments_created_total")), "comments"},
+		query{fmt.Sprintf(`sum by (action) (increase(%s[1h]))`, m("friendships_total")), "friendships {{action}}"})
+	l.timeseries("Tags per post p50 / p95", "short", "",
+		query{quantile(0.5, "post_tags", ""), "p50"},
+		query{quantile(0.95, "post_tags", ""), "p95"})
+
+	l.row("Media")
+	l.timeseries("Upload bytes", "Bps", "",
+		query{fmt.Sprintf(`rate(%s[5m])`, m("media_upload_bytes_sum")), "uploaded"},
+		query{quantile(0.95, "media_upload_bytes", ""), "p95 upload size"})
+	l.timeseries("Upload failures by reason", "short", "",
+		query{fmt.Sprintf(`sum by (reason) (increase(%s[1h]))`, m("media_upload_failures_total")), "{{reason}}"})
+	l.timeseries("Orphaned media collected", "bytes", "",
+		query{fmt.Sprintf(`sum by (dry_run) (increase(%s[1h]))`, m("media_gc_reclaimed_bytes_total")), "reclaimed, dry run {{dry_run}}"},
+		query{fmt.Sprintf(`sum by (result) (increase(%s[1h]))`, m("media_gc_runs_total")), "runs {{result}}"})
+
+	return dashboard{
+		UID:           "social-media-10k-rps",
+		Title:         "Social Media 10k RPS",
+		Tags:          []string{"social-media-10k-rps", "generated"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Version:       1,
+		Editable:      false,
+		Refresh:       "10s",
+		Time:          map[string]any{"from": "now-1h", "to": "now"},
+		Templating:    map[string]any{"list": []any{}},
+		Panels:        l.panels,
+	}
+}
+
+type (
+	rule struct {
+		Alert       string
+		Expr        string
+		For         string
+		Severity    string
+		Summary     string
+		Description string
+	}
+	ruleGroup struct {
+		Name  string
+		Rules []rule
+	}
+)
+
+func alertGroups() []ruleGroup {
+	return []ruleGroup{
+		{Name: "http", Rules: []rule{
+			{
+				Alert:       "LatencySLOBreach",
+				Expr:        fmt.Sprintf(`1 - sum(rate(%s{le="0.5"}[30m])) / sum(rate(%s[30m])) > 0.01`, m("http_request_duration_seconds_bucket"), m("http_request_duration_seconds_count")),
+				For:         "10m",
+				Severity:    "page",
+				Summary:     "More than 1% of requests take over 500ms",
+				Description: "The latency SLO is 99% of requests served within 500ms over 30 minutes.",
+			},
+			{
+				Alert:       "HighRouteLatency",
+				Expr:        fmt.Sprintf(`%s > 1`, quantile(0.95, "http_request_duration_seconds", ", route")),
+				For:         "10m",
+				Severity:    "ticket",
+				Summary:     "p95 latency of {{ $labels.route }} is above 1s",
+				Description: "p95 latency is {{ $value | humanizeDuration }}.",
+			},
+			{
+				Alert:       "HighErrorRate",
+				Expr:        fmt.Sprintf(`sum(rate(%[1]s{status=~"5.."}[5m])) / sum(rate(%[1]s[5m])) > 0.05`, m("http_requests_total")),
+				For:         "5m",
+				Severity:    "page",
+				Summary:     "More than 5% of requests fail with a 5xx",
+				Description: "Error ratio is {{ $value | humanizePercentage }}.",
+			},
+		}},
+		{Name: "database", Rules: []rule{
+			{
+				Alert:       "DBPoolSaturated",
+				Expr:        fmt.Sprintf(`%s / %s > 0.9`, m("pgxpool_acquired_conns"), m("pgxpool_max_conns")),
+				For:         "5m",
+				Severity:    "page",
+				Summary:     "Database pool is over 90% acquired",
+				Description: "{{ $value | humanizePercentage }} of the pool connections are in use.",
+			},
+			{
+				Alert:       "DBPoolSlowAcquire",
+				Expr:        fmt.Sprintf(`rate(%s[5m]) / rate(%s[5m]) > 0.05`, m("pgxpool_acquire_duration_seconds_total"), m("pgxpool_acquires_total")),
+				For:         "5m",
+				Severity:    "ticket",
+				Summary:     "Acquiring a database connection takes over 50ms",
+				Description: "Average acquire wait is {{ $value | humanizeDuration }}.",
+			},
+		}},
+		{Name: "media", Rules: []rule{
+			{
+				Alert:       "UploadFailures",
+				Expr:        fmt.Sprintf(`sum(rate(%s{reason="internal"}[10m])) > 0`, m("media_upload_failures_total")),
+				For:         "10m",
+				Severity:    "ticket",
+				Summary:     "Uploads are failing on the server side",
+				Description: "Uploads fail for reasons other than a rejected file, check storage and the database.",
+			},
+			{
+				Alert:       "HighUploadRejectRate",
+				Expr:        fmt.Sprintf(`sum(rate(%s[30m])) / (sum(rate(%s[30m])) + sum(rate(%s[30m]))) > 0.25`, m("media_upload_failures_total"), m("media_upload_failures_total"), m("media_upload_bytes_count")),
+				For:         "30m",
+				Severity:    "ticket",
+				Summary:     "More than 25% of uploads fail",
+				Description: "Either clients send files the upload rules reject or uploads are failing.",
+			},
+			{
+				Alert:       "MediaGCFailing",
+				Expr:        fmt.Sprintf(`increase(%s{result="error"}[3h]) > 0`, m("media_gc_runs_total")),
+				Severity:    "ticket",
+				Summary:     "Orphaned media collection is failing",
+				Description: "Unreferenced uploads are not being deleted and keep counting against quotas.",
+			},
+		}},
+	}
+}
+
+// The $labels and $value templates are Prometheus', the template delimiters
+// are swapped so they pass through untouched.
+var rulesTemplate = template.Must(template.New("rules").Delims("[[", "]]").Parse(`# Code generated by monitoring/gen. DO NOT EDIT.
+groups:
+[[- range .]]
+  - name: [[.Name]]
+    rules:
+[[- range .Rules]]
+      - alert: [[.Alert]]
+        expr: '[[.Expr]]'
+[[- if .For]]
+        for: [[.For]]
+[[- end]]
+        labels:
+          severity: [[.Severity]]
+        annotations:
+          summary: '[[.Summary]]'
+          description: '[[.Description]]'
+[[- end]]
+[[- end]]
+`))
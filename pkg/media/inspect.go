@@ -0,0 +1,94 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"net/http"
+
+	// registers the decoders used by image.Decode
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrTooSmall        = errors.New("media: file is too small")
+	ErrTooLarge        = errors.New("media: file is too large")
+	ErrUnsupportedType = errors.New("media: file type is not allowed")
+	ErrMalformed       = errors.New("media: file is not a valid image")
+	ErrDimensions      = errors.New("media: image dimensions are out of bounds")
+)
+
+// Rules restricts what an upload may be, AllowedTypes are mime types.
+type Rules struct {
+	MinBytes     int64
+	MaxBytes     int64
+	AllowedTypes []string
+	MaxWidth     int
+	MaxHeight    int
+}
+
+type Info struct {
+	MimeType string
+	Ext      string
+	Width    int
+	Height   int
+}
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Inspect identifies an image by its magic bytes rather than its file name,
+// checks it against rules and fully decodes it to make sure it is well-formed.
+func Inspect(data []byte, rules Rules) (Info, error) {
+	info := Info{}
+
+	size := int64(len(data))
+	if size < rules.MinBytes {
+		return info, ErrTooSmall
+	}
+	if rules.MaxBytes > 0 && size > rules.MaxBytes {
+		return info, ErrTooLarge
+	}
+
+	info.MimeType = http.DetectContentType(data)
+	info.Ext = extensions[info.MimeType]
+	if info.Ext == "" || !allowed(info.MimeType, rules.AllowedTypes) {
+		return info, ErrUnsupportedType
+	}
+
+	// check dimensions from the header first so a decompression bomb is never decoded
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || "image/"+format != info.MimeType {
+		return info, ErrMalformed
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		(rules.MaxWidth > 0 && config.Width > rules.MaxWidth) ||
+		(rules.MaxHeight > 0 && config.Height > rules.MaxHeight) {
+		return info, ErrDimensions
+	}
+	info.Width = config.Width
+	info.Height = config.Height
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return info, ErrMalformed
+	}
+
+	return info, nil
+}
+
+func allowed(mimeType string, allowedTypes []string) bool {
+	for _, t := range allowedTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
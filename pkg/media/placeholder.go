@@ -0,0 +1,157 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// Placeholder is what a client shows while the image itself is loading.
+type Placeholder struct {
+	BlurHash string // see https://blurha.sh
+	Color    string // dominant color as #rrggbb
+}
+
+// placeholderSize is the longest side the image is scaled down to first, a
+// blur doesn't need more and it keeps the cost flat for large uploads.
+const placeholderSize = 32
+
+// NewPlaceholder computes the BlurHash and dominant color of img.
+func NewPlaceholder(img image.Image) Placeholder {
+	small := resize(img, variantSpec{size: placeholderSize}).(*image.RGBA)
+
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+	xComponents, yComponents := 4, 3
+	if h > w {
+		xComponents, yComponents = 3, 4
+	}
+
+	return Placeholder{
+		BlurHash: blurHash(small, xComponents, yComponents),
+		Color:    dominantColor(small),
+	}
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+func base83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func sRGBToLinear(v uint8) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// blurHash encodes img following the reference implementation, every
+// component is a cosine factor over the linear RGB values.
+func blurHash(img *image.RGBA, xComponents, yComponents int) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := img.RGBAAt(x, y)
+					factor[0] += basis * sRGBToLinear(p.R)
+					factor[1] += basis * sRGBToLinear(p.G)
+					factor[2] += basis * sRGBToLinear(p.B)
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	base83(&sb, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		base83(&sb, quantisedMax, 1)
+	} else {
+		base83(&sb, 0, 1)
+	}
+
+	base83(&sb, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		base83(&sb, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+
+	return sb.String()
+}
+
+// dominantColor buckets the pixels by their top 4 bits per channel and
+// averages the fullest bucket, transparent pixels don't count.
+func dominantColor(img *image.RGBA) string {
+	type bucket struct {
+		n       int
+		r, g, b int
+	}
+	var buckets [4096]bucket
+
+	best := -1
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			p := img.RGBAAt(x, y)
+			if p.A == 0 {
+				continue
+			}
+			i := int(p.R>>4)<<8 | int(p.G>>4)<<4 | int(p.B>>4)
+			buckets[i].n++
+			buckets[i].r += int(p.R)
+			buckets[i].g += int(p.G)
+			buckets[i].b += int(p.B)
+			if best < 0 || buckets[i].n > buckets[best].n {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		return "#000000"
+	}
+
+	b := buckets[best]
+	return fmt.Sprintf("#%02x%02x%02x", b.r/b.n, b.g/b.n, b.b/b.n)
+}
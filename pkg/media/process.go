@@ -0,0 +1,140 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	VariantThumbnail = "thumbnail"
+	VariantMedium    = "medium"
+	VariantOriginal  = "original"
+
+	// VariantAvatar is the variant used for profile images.
+	VariantAvatar = VariantThumbnail
+)
+
+type variantSpec struct {
+	name string
+	size int  // longest side, 0 keeps the original size
+	crop bool // crop to a size x size square
+}
+
+var variantSpecs = []variantSpec{
+	{VariantThumbnail, 200, true},
+	{VariantMedium, 800, false},
+	{VariantOriginal, 0, false},
+}
+
+type Variant struct {
+	Name     string
+	Data     []byte
+	MimeType string
+	Ext      string
+	Width    int
+	Height   int
+}
+
+// Process re-encodes an inspected image into every variant. Re-encoding with
+// the standard codecs drops EXIF, GPS and any other metadata, the JPEG
+// orientation tag is applied to the pixels first so nothing ends up sideways.
+// JPEG stays JPEG, everything else is written as PNG since there is no pure-Go
+// WebP encoder, animated GIFs keep their first frame only. The placeholder is
+// computed from the same decoded image.
+func Process(data []byte, info Info) ([]Variant, Placeholder, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Placeholder{}, ErrMalformed
+	}
+	if info.MimeType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	variants := make([]Variant, 0, len(variantSpecs))
+	for _, spec := range variantSpecs {
+		resized := resize(img, spec)
+
+		variant := Variant{Name: spec.name, Width: resized.Bounds().Dx(), Height: resized.Bounds().Dy()}
+		var buf bytes.Buffer
+		if info.MimeType == "image/jpeg" {
+			variant.MimeType, variant.Ext = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+		} else {
+			variant.MimeType, variant.Ext = "image/png", ".png"
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, Placeholder{}, err
+		}
+		variant.Data = buf.Bytes()
+
+		variants = append(variants, variant)
+	}
+
+	return variants, NewPlaceholder(img), nil
+}
+
+// VariantKey names the object of a variant, all variants of one upload share id.
+func VariantKey(id, variant, ext string) string {
+	return fmt.Sprintf("%s_%s%s", id, variant, ext)
+}
+
+// ParseVariantKey splits a key made by VariantKey, or the last path element
+// of its URL, back into the upload id and variant name.
+func ParseVariantKey(key string) (id, variant string, ok bool) {
+	base := path.Base(key)
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	i := strings.LastIndex(base, "_")
+	if i <= 0 {
+		return "", "", false
+	}
+	id, variant = base[:i], base[i+1:]
+	for _, spec := range variantSpecs {
+		if spec.name == variant {
+			return id, variant, true
+		}
+	}
+	return "", "", false
+}
+
+func resize(img image.Image, spec variantSpec) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if spec.crop {
+		side := w
+		if h < side {
+			side = h
+		}
+		x := b.Min.X + (w-side)/2
+		y := b.Min.Y + (h-side)/2
+		b = image.Rect(x, y, x+side, y+side)
+		w, h = side, side
+	}
+
+	if spec.size > 0 && (w > spec.size || h > spec.size) {
+		if w >= h {
+			w, h = spec.size, h*spec.size/w
+		} else {
+			w, h = w*spec.size/h, spec.size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
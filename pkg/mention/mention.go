@@ -0,0 +1,79 @@
+package mention
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Mention is a reference to a user inside a text. Start and End are byte
+// offsets of the whole `@<user id>` token.
+type Mention struct {
+	UserID string
+	Start  int
+	End    int
+}
+
+var mentionRegex = regexp.MustCompile(`@([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})\b`)
+
+func Parse(text string) []Mention {
+	matches := mentionRegex.FindAllStringSubmatchIndex(text, -1)
+
+	mentions := make([]Mention, 0, len(matches))
+	for _, m := range matches {
+		mentions = append(mentions, Mention{
+			UserID: strings.ToLower(text[m[2]:m[3]]),
+			Start:  m[0],
+			End:    m[1],
+		})
+	}
+
+	return mentions
+}
+
+// ParseHTML is Parse for HTML, only the text is searched so an id inside a
+// tag, like in a link's href, isn't a mention. Offsets are still into s.
+func ParseHTML(s string) []Mention {
+	mentions := []Mention{}
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return mentions
+			}
+			break
+		}
+
+		raw := z.Raw()
+		if tt == html.TextToken {
+			for _, m := range Parse(string(raw)) {
+				m.Start += offset
+				m.End += offset
+				mentions = append(mentions, m)
+			}
+		}
+		offset += len(raw)
+	}
+
+	return mentions
+}
+
+// UserIDs returns the distinct mentioned users in order of first appearance.
+func UserIDs(mentions []Mention) []string {
+	seen := make(map[string]bool, len(mentions))
+	ids := make([]string, 0, len(mentions))
+	for _, m := range mentions {
+		if seen[m.UserID] {
+			continue
+		}
+		seen[m.UserID] = true
+		ids = append(ids, m.UserID)
+	}
+
+	return ids
+}
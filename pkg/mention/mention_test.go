@@ -0,0 +1,54 @@
+package mention
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	id1 = "0b6f1d1e-6a53-4c5e-9d7e-1f1f3b1c2a01"
+	id2 = "0b6f1d1e-6a53-4c5e-9d7e-1f1f3b1c2a02"
+)
+
+func TestParse(t *testing.T) {
+	text := "hi @" + id1 + " and @" + "0B6F1D1E-6A53-4C5E-9D7E-1F1F3B1C2A02!"
+	want := []Mention{
+		{UserID: id1, Start: 3, End: 40},
+		{UserID: id2, Start: 45, End: 82},
+	}
+	if got := Parse(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"text", "<p>hi @" + id1 + "</p>", []string{id1}},
+		{"href", `<a href="https://x/@` + id1 + `" rel="nofollow">profile</a>`, nil},
+		{"link text", `<a href="https://x/" rel="nofollow">@` + id1 + `</a>`, []string{id1}},
+		{"attribute and text", `<a href="https://x/@` + id1 + `" rel="nofollow">@` + id2 + `</a>`, []string{id2}},
+		{"entities before", "<p>&lt;b&gt; &amp; @" + id1 + "</p>", []string{id1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseHTML(tt.in)
+
+			var ids []string
+			for _, m := range got {
+				ids = append(ids, m.UserID)
+				// offsets point into the HTML as stored
+				if token := tt.in[m.Start:m.End]; token != "@"+m.UserID {
+					t.Errorf("ParseHTML(%q) offsets [%d:%d] = %q", tt.in, m.Start, m.End, token)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ParseHTML(%q) users = %v, want %v", tt.in, ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+// Package migrate applies SQL migrations laid out and recorded the way
+// golang-migrate does, NNNNNN_name.up.sql and .down.sql files and a single
+// row schema_migrations table, so databases migrated with the CLI carry on.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockID is the advisory lock held while migrating, replicas starting at the
+// same time wait on it instead of running the same migration twice.
+const lockID int64 = 0x6d6967726174
+
+type Migration struct {
+	Version int
+	Name    string
+
+	up   string
+	down string
+}
+
+type Status struct {
+	Migration
+	Applied bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.*)\.(up|down)\.sql$`)
+
+// Load reads the migrations in dir of fsys sorted by version, every one of
+// them needs both an up and a down file.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if m[3] == "up" {
+			mig.up = string(b)
+		} else {
+			mig.down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the highest version in dir of fsys, 0 if there's none.
+func Latest(fsys fs.FS, dir string) (int, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(pool *pgxpool.Pool, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Up applies every migration newer than the current version and returns the
+// ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+			if err := run(ctx, conn, mig.up, mig.Version); err != nil {
+				return fmt.Errorf("fail apply %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the last n applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+
+		i := sort.Search(len(m.migrations), func(i int) bool {
+			return m.migrations[i].Version >= version
+		})
+		if i == len(m.migrations) || m.migrations[i].Version != version {
+			return fmt.Errorf("database is at version %d which isn't a known migration", version)
+		}
+
+		for ; i >= 0 && len(reverted) < n; i-- {
+			mig := m.migrations[i]
+			target := 0
+			if i > 0 {
+				target = m.migrations[i-1].Version
+			}
+			if err := run(ctx, conn, mig.down, target); err != nil {
+				return fmt.Errorf("fail revert %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Version returns the current version, 0 when no migration ran yet. dirty
+// is set when a migration failed halfway and the schema needs fixing by hand.
+func (m *Migrator) Version(ctx context.Context) (int, bool, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Release()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+	return version(ctx, conn)
+}
+
+// Status lists every known migration and whether it's applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, int, bool, error) {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	res := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		res = append(res, Status{Migration: mig, Applied: mig.Version <= version})
+	}
+	return res, version, dirty, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("fail take migration lock: %w", err)
+	}
+	// unlock even when ctx was canceled, the connection goes back to the pool
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) cleanVersion(ctx context.Context, conn *pgxpool.Conn) (int, error) {
+	version, dirty, err := version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d, fix the schema and clear schema_migrations.dirty first", version)
+	}
+	return version, nil
+}
+
+func ensureTable(ctx context.Context, conn *pgxpool.Conn) error {
+	q := `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`
+	_, err := conn.Exec(ctx, q)
+	return err
+}
+
+func version(ctx context.Context, conn *pgxpool.Conn) (int, bool, error) {
+	q := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+	version := 0
+	dirty := false
+	err := conn.QueryRow(ctx, q).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return version, dirty, nil
+}
+
+// run marks target as dirty, executes sql and marks target clean, same as
+// golang-migrate so a failure leaves a version the CLI understands too.
+func run(ctx context.Context, conn *pgxpool.Conn, sql string, target int) error {
+	if err := setVersion(ctx, conn, target, true); err != nil {
+		return err
+	}
+	if strings.TrimSpace(sql) != "" {
+		// no arguments, pgx sends it over the simple protocol which allows
+		// the several statements a migration file has
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	return setVersion(ctx, conn, target, false)
+}
+
+// setVersion records target, version 0 means nothing is applied and leaves
+// the table empty.
+func setVersion(ctx context.Context, conn *pgxpool.Conn, target int, dirty bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if target > 0 || dirty {
+		q := `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`
+		if _, err := tx.Exec(ctx, q, target, dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
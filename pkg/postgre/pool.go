@@ -2,36 +2,64 @@ package postgre
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func GetConn(ctx context.Context) *pgxpool.Pool {
-	dbName := os.Getenv("DB_NAME")
-	dbPort := os.Getenv("DB_PORT")
-	dbHost := os.Getenv("DB_HOST")
-	dbUsername := os.Getenv("DB_USERNAME")
-	dbPassword := os.Getenv("DB_PASSWORD")
+type Config struct {
+	Name     string
+	Port     int
+	Host     string
+	Username string
+	Password string
+	// SSLMode and SSLRootCert are passed on as sslmode and sslrootcert
+	SSLMode     string
+	SSLRootCert string
 
-	dbUrl := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbUsername, dbPassword, dbHost, dbPort, dbName)
-	if os.Getenv("ENV") == "production" {
-		dbUrl = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=verify-full&sslrootcert=ap-southeast-1-bundle.pem", dbUsername, dbPassword, dbHost, dbPort, dbName)
+	MaxConns        int
+	MaxConnIdleTime time.Duration
+	MaxConnLifetime time.Duration
+	ConnectTimeout  time.Duration
+}
+
+// URL is the connection string for c, it holds the password.
+func (c Config) URL() string {
+	query := url.Values{}
+	if c.SSLMode != "" {
+		query.Set("sslmode", c.SSLMode)
+	}
+	if c.SSLRootCert != "" {
+		query.Set("sslrootcert", c.SSLRootCert)
 	}
 
-	config, err := pgxpool.ParseConfig(dbUrl)
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Username, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     c.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// GetConn connects the pool, tracer may be nil.
+func GetConn(ctx context.Context, c Config, tracer pgx.QueryTracer) *pgxpool.Pool {
+	config, err := pgxpool.ParseConfig(c.URL())
 	if err != nil {
 		log.Fatalf("Unable to parse connection string: %v", err)
 	}
 
-	config.MaxConns = 100
-	config.MaxConnIdleTime = time.Second * 5
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = time.Minute * 30
-	config.ConnConfig.ConnectTimeout = time.Second * 5
+	config.MaxConns = int32(c.MaxConns)
+	config.MaxConnLifetime = c.MaxConnLifetime
+	config.MaxConnIdleTime = c.MaxConnIdleTime
+	config.ConnConfig.ConnectTimeout = c.ConnectTimeout
+	config.ConnConfig.Tracer = tracer
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
@@ -1,13 +1,11 @@
 package router
 
 import (
-	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 )
 
 func NewRouter() *chi.Mux {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
 
 	return r
 }
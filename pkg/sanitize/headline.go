@@ -0,0 +1,25 @@
+package sanitize
+
+import (
+	"html"
+	"strings"
+)
+
+// HeadlineStart and HeadlineStop mark the matches in a ts_headline instead of
+// HTML tags. They are private use characters, strip them from the text before
+// highlighting so the text itself can't fake a match.
+const (
+	HeadlineStart = "\uE000"
+	HeadlineStop  = "\uE001"
+)
+
+// HeadlineOptions are the ts_headline options producing the markers above.
+const HeadlineOptions = `StartSel="` + HeadlineStart + `", StopSel="` + HeadlineStop + `", MaxFragments=2`
+
+// Headline escapes a plain text headline and turns its markers into <mark>
+// tags, the result is safe to render as HTML.
+func Headline(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, HeadlineStart, "<mark>")
+	return strings.ReplaceAll(s, HeadlineStop, "</mark>")
+}
@@ -0,0 +1,54 @@
+package sanitize
+
+import "testing"
+
+func TestHeadline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "marks matches",
+			in:   "hello " + HeadlineStart + "world" + HeadlineStop,
+			want: "hello <mark>world</mark>",
+		},
+		{
+			// what PlainText stores for a post containing &lt;img ...&gt;
+			name: "escapes markup in the text",
+			in:   "hi <img src=x onerror=alert(1)> " + HeadlineStart + "there" + HeadlineStop,
+			want: "hi &lt;img src=x onerror=alert(1)&gt; <mark>there</mark>",
+		},
+		{
+			name: "escapes literal mark tags",
+			in:   "</mark><script>alert(1)</script>",
+			want: "&lt;/mark&gt;&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name: "escapes quotes and ampersands",
+			in:   `Tom & "Jerry"`,
+			want: "Tom &amp; &#34;Jerry&#34;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Headline(tt.in); got != tt.want {
+				t.Errorf("Headline(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainTextHeadlineIsEscaped(t *testing.T) {
+	text := PlainText("<p>hi &lt;img src=x onerror=alert(1)&gt; there</p>")
+	if text != "hi <img src=x onerror=alert(1)> there" {
+		t.Fatalf("PlainText = %q, the projection is expected to be unescaped", text)
+	}
+
+	got := Headline(text)
+	want := "hi &lt;img src=x onerror=alert(1)&gt; there"
+	if got != want {
+		t.Errorf("Headline(%q) = %q, want %q", text, got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var (
+	postPolicy  = newPostPolicy()
+	stripPolicy = bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
+
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// newPostPolicy allows basic formatting and links only. Scripts, styles, event
+// handlers and any URL scheme other than http, https and mailto are removed.
+func newPostPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("p", "br", "b", "strong", "i", "em", "u", "s", "blockquote", "code", "pre", "ul", "ol", "li", "span")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// PostHTML returns the post content with everything outside the allow-list stripped.
+func PostHTML(s string) string {
+	return strings.TrimSpace(postPolicy.Sanitize(s))
+}
+
+// PlainText projects HTML into plain text with collapsed whitespace, used for
+// search and notifications.
+func PlainText(s string) string {
+	text := html.UnescapeString(stripPolicy.Sanitize(s))
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+}
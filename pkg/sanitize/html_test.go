@@ -0,0 +1,121 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "keeps allowed formatting",
+			in:   "<p><b>bold</b> <em>em</em></p>",
+			want: "<p><b>bold</b> <em>em</em></p>",
+		},
+		{
+			name: "drops script elements with their content",
+			in:   `<p>hi</p><script>alert(1)</script>`,
+			want: "<p>hi</p>",
+		},
+		{
+			name: "drops event handlers",
+			in:   `<p onclick="alert(1)">hi</p><img src=x onerror=alert(1)>`,
+			want: "<p>hi</p>",
+		},
+		{
+			name: "drops javascript links",
+			in:   `<a href="javascript:alert(1)">x</a>`,
+			want: "x",
+		},
+		{
+			name: "drops obfuscated javascript links",
+			in:   `<a href="jav&#x09;ascript:alert(1)">x</a><a href=" JaVaScRiPt:alert(1)">y</a>`,
+			want: "xy",
+		},
+		{
+			name: "keeps http links as nofollow",
+			in:   `<a href="https://example.com">x</a>`,
+			want: `<a href="https://example.com" rel="nofollow noopener" target="_blank">x</a>`,
+		},
+		{
+			name: "drops style and iframe",
+			in:   `<style>p{}</style><iframe src="https://example.com"></iframe><p style="color:red">hi</p>`,
+			want: "<p>hi</p>",
+		},
+		{
+			name: "keeps escaped markup escaped",
+			in:   `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+			want: `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PostHTML(tt.in); got != tt.want {
+				t.Errorf("PostHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// sanitizing what was already sanitized must not change it, or stored
+// content would double-escape every time it is rewritten
+func TestPostHTMLIsIdempotent(t *testing.T) {
+	inputs := []string{
+		`<p>Tom &amp; Jerry &lt;3 &#34;quotes&#34; &#39;single&#39;</p>`,
+		`<p>a < b && c > d</p>`,
+		`<a href="https://example.com/?a=1&b=2">link</a>`,
+		`<p>caf&eacute; &nbsp; &#x1F600;</p>`,
+	}
+
+	for _, in := range inputs {
+		once := PostHTML(in)
+		twice := PostHTML(once)
+		if once != twice {
+			t.Errorf("PostHTML not idempotent for %q: %q then %q", in, once, twice)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "strips tags and collapses whitespace",
+			in:   "<p>hello</p>\n\n<p>  world </p>",
+			want: "hello world",
+		},
+		{
+			name: "unescapes entities",
+			in:   "<p>Tom &amp; Jerry &lt;3 caf&eacute;</p>",
+			want: "Tom & Jerry <3 café",
+		},
+		{
+			name: "drops script content",
+			in:   "<p>hi</p><script>alert(1)</script>",
+			want: "hi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlainText(tt.in); got != tt.want {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainTextOfSanitizedHasNoMarkup(t *testing.T) {
+	text := PlainText(PostHTML(`<p>x</p><img src=x onerror=alert(1)><script>alert(2)</script>`))
+	if strings.ContainsAny(text, "<>") {
+		t.Errorf("PlainText kept markup: %q", text)
+	}
+}
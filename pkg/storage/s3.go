@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type S3Config struct {
+	ID         string
+	SecretKey  string
+	BucketName string
+	Region     string
+	// Endpoint is set for S3 compatible stores such as MinIO, empty means AWS.
+	Endpoint string
+	// PublicURL overrides the base of the URLs handed to clients, e.g. a CDN.
+	PublicURL string
+}
+
+type s3Storage struct {
+	client *s3.S3
+	cfg    S3Config
+}
+
+func NewS3(cfg S3Config) (Storage, error) {
+	awsCfg := &aws.Config{
+		Region:      aws.String(cfg.Region),
+		Credentials: credentials.NewStaticCredentials(cfg.ID, cfg.SecretKey, ""),
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cfg.Endpoint)
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Storage{s3.New(sess), cfg}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.cfg.BucketName),
+		Key:         aws.String(key),
+		ACL:         aws.String("public-read"),
+		ContentType: aws.String(contentType),
+		Body:        body,
+	})
+	return err
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return out.Body, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *s3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.cfg.BucketName),
+	})
+	return err
+}
+
+func (s *s3Storage) URL(key string) string {
+	if s.cfg.PublicURL != "" {
+		return fmt.Sprintf("%s/%s", strings.TrimSuffix(s.cfg.PublicURL, "/"), key)
+	}
+	if s.cfg.Endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.cfg.Endpoint, "/"), s.cfg.BucketName, key)
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", s.cfg.BucketName, key)
+}
+
+func (s *s3Storage) PresignPut(key, contentType string, contentLength int64, expires time.Duration) (string, map[string]string, error) {
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.cfg.BucketName),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(contentLength),
+	})
+
+	url, signed, err := req.PresignRequest(expires)
+	if err != nil {
+		return "", nil, err
+	}
+
+	headers := make(map[string]string, len(signed))
+	for k := range signed {
+		headers[k] = signed.Get(k)
+	}
+
+	return url, headers, nil
+}
+
+func (s *s3Storage) ListOlder(ctx context.Context, prefix string, before time.Time, limit int) ([]string, error) {
+	keys := make([]string, 0, limit)
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.BucketName),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			if object.LastModified != nil && object.LastModified.Before(before) {
+				keys = append(keys, aws.StringValue(object.Key))
+				if len(keys) == limit {
+					return false
+				}
+			}
+		}
+		return true
+	})
+
+	return keys, err
+}
+
+func (s *s3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		// a HEAD response has no body to carry NoSuchKey
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+			return ObjectInfo{}, ErrNotFound
+		}
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{Size: aws.Int64Value(out.ContentLength), ContentType: aws.StringValue(out.ContentType)}, nil
+}
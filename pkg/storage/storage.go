@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage is an object store keyed by flat names, the upload handlers only
+// talk to this so the backend can be swapped through config.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	// Ping checks the backend can be reached, for readiness checks.
+	Ping(ctx context.Context) error
+}
+
+var ErrPresignUnsupported = errors.New("storage: backend does not support presigned uploads")
+
+// ObjectInfo is what the backend knows about an object without reading it.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Presigner is implemented by backends clients can upload to directly.
+type Presigner interface {
+	// PresignPut returns a URL accepting a single PUT of exactly contentLength
+	// bytes of contentType, along with the headers the client must send.
+	PresignPut(key, contentType string, contentLength int64, expires time.Duration) (string, map[string]string, error)
+	// ListOlder returns up to limit keys starting with prefix that were last
+	// written before before, uploads that were never completed are found
+	// through it.
+	ListOlder(ctx context.Context, prefix string, before time.Time, limit int) ([]string, error)
+	// Stat returns the size and content type of key without reading its
+	// body, ErrNotFound when there's no such object.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+}